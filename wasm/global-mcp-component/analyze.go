@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// regionCentrality holds the per-region scores of global.analyze_graph.
+type regionCentrality struct {
+	Rank              int     `json:"rank"`
+	RegionID          string  `json:"regionId"`
+	RegionName        string  `json:"regionName"`
+	Score             float64 `json:"score"`
+	WeightedIn        float64 `json:"weightedIn"`
+	WeightedOut       float64 `json:"weightedOut"`
+	WeightedDegree    float64 `json:"weightedDegree"`
+	Betweenness       float64 `json:"betweenness"`
+	PageRank          float64 `json:"pageRank"`
+	FlowCentrality    float64 `json:"flowCentrality"`
+	ImportHHI         float64 `json:"importHhi"`
+	ArticulationPoint bool    `json:"articulationPoint"`
+}
+
+type graphBridge struct {
+	Source string  `json:"source"`
+	Target string  `json:"target"`
+	Weight float64 `json:"weight"`
+}
+
+var rankMeasures = []string{"score", "weighted_degree", "betweenness", "pagerank", "flow"}
+
+// analyzeGraph ranks the regions of a flow network. The chokepoint score is
+// the mean of weighted degree, betweenness, PageRank and flow centrality, each
+// scaled so the highest region has 1, plus 0.25 for articulation points.
+func analyzeGraph(args map[string]any) (any, error) {
+	net, err := flowNetworkFromArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	rankBy, _ := args["rank_by"].(string)
+	if rankBy == "" {
+		rankBy = "score"
+	}
+	if !containsString(rankMeasures, rankBy) {
+		return nil, fmt.Errorf("rank_by must be one of %v", rankMeasures)
+	}
+
+	n := len(net.Nodes)
+	out := make([]regionCentrality, n)
+	for i, id := range net.Nodes {
+		out[i] = regionCentrality{RegionID: id, RegionName: regionName(id)}
+		for j := 0; j < n; j++ {
+			out[i].WeightedOut += net.Cap[i][j]
+			out[i].WeightedIn += net.Cap[j][i]
+		}
+		out[i].WeightedDegree = out[i].WeightedIn + out[i].WeightedOut
+		if out[i].WeightedIn > 0 {
+			for j := 0; j < n; j++ {
+				share := net.Cap[j][i] / out[i].WeightedIn
+				out[i].ImportHHI += share * share
+			}
+		}
+	}
+	betweenness := weightedBetweenness(net)
+	pagerank := weightedPageRank(net, 0.85)
+	flow := flowCentrality(net)
+	articulation, bridges := articulationAndBridges(net)
+	for i := range out {
+		out[i].Betweenness = round6(betweenness[i])
+		out[i].PageRank = round6(pagerank[i])
+		out[i].FlowCentrality = round6(flow[i])
+		out[i].ImportHHI = round6(out[i].ImportHHI)
+		out[i].ArticulationPoint = articulation[i]
+	}
+
+	maxOf := func(get func(regionCentrality) float64) float64 {
+		m := 0.0
+		for _, r := range out {
+			m = math.Max(m, get(r))
+		}
+		return m
+	}
+	scaled := func(v, max float64) float64 {
+		if max <= 0 {
+			return 0
+		}
+		return v / max
+	}
+	maxDeg := maxOf(func(r regionCentrality) float64 { return r.WeightedDegree })
+	maxBet := maxOf(func(r regionCentrality) float64 { return r.Betweenness })
+	maxPR := maxOf(func(r regionCentrality) float64 { return r.PageRank })
+	maxFlow := maxOf(func(r regionCentrality) float64 { return r.FlowCentrality })
+	for i := range out {
+		r := &out[i]
+		score := (scaled(r.WeightedDegree, maxDeg) + scaled(r.Betweenness, maxBet) + scaled(r.PageRank, maxPR) + scaled(r.FlowCentrality, maxFlow)) / 4
+		if r.ArticulationPoint {
+			score += 0.25
+		}
+		r.Score = round6(score)
+	}
+
+	key := func(r regionCentrality) float64 {
+		switch rankBy {
+		case "weighted_degree":
+			return r.WeightedDegree
+		case "betweenness":
+			return r.Betweenness
+		case "pagerank":
+			return r.PageRank
+		case "flow":
+			return r.FlowCentrality
+		}
+		return r.Score
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if key(out[i]) != key(out[j]) {
+			return key(out[i]) > key(out[j])
+		}
+		return out[i].RegionID < out[j].RegionID
+	})
+	points := make([]string, 0)
+	for i := range out {
+		out[i].Rank = i + 1
+		if out[i].ArticulationPoint {
+			points = append(points, out[i].RegionID)
+		}
+	}
+	return map[string]any{
+		"resource_id":         net.ResourceID,
+		"year":                net.Year,
+		"weight_by":           net.WeightBy,
+		"rank_by":             rankBy,
+		"nodes":               n,
+		"total_weight":        round6(net.total()),
+		"ranking":             out,
+		"articulation_points": points,
+		"bridges":             bridges,
+	}, nil
+}
+
+// weightedBetweenness is Brandes' algorithm with Dijkstra, taking 1/weight as
+// edge length so heavier flows are shorter paths. Scores are normalized by
+// (n-1)(n-2), the number of ordered pairs excluding the node.
+func weightedBetweenness(net *flowNetwork) []float64 {
+	n := len(net.Nodes)
+	cb := make([]float64, n)
+	for s := 0; s < n; s++ {
+		dist := make([]float64, n)
+		sigma := make([]float64, n)
+		preds := make([][]int, n)
+		done := make([]bool, n)
+		for i := range dist {
+			dist[i] = math.Inf(1)
+		}
+		dist[s], sigma[s] = 0, 1
+		order := make([]int, 0, n)
+		for {
+			u := -1
+			for i := 0; i < n; i++ {
+				if !done[i] && !math.IsInf(dist[i], 1) && (u < 0 || dist[i] < dist[u]) {
+					u = i
+				}
+			}
+			if u < 0 {
+				break
+			}
+			done[u] = true
+			order = append(order, u)
+			for v := 0; v < n; v++ {
+				if net.Cap[u][v] <= 0 || done[v] {
+					continue
+				}
+				d := dist[u] + 1/net.Cap[u][v]
+				switch {
+				case d < dist[v]-1e-12:
+					dist[v], sigma[v], preds[v] = d, sigma[u], []int{u}
+				case math.Abs(d-dist[v]) <= 1e-12:
+					sigma[v] += sigma[u]
+					preds[v] = append(preds[v], u)
+				}
+			}
+		}
+		delta := make([]float64, n)
+		for i := len(order) - 1; i >= 0; i-- {
+			w := order[i]
+			for _, v := range preds[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != s {
+				cb[w] += delta[w]
+			}
+		}
+	}
+	if n > 2 {
+		for i := range cb {
+			cb[i] /= float64((n - 1) * (n - 2))
+		}
+	}
+	return cb
+}
+
+// weightedPageRank follows out-edges in proportion to their weight. Regions
+// without exports spread their rank evenly.
+func weightedPageRank(net *flowNetwork, damping float64) []float64 {
+	n := len(net.Nodes)
+	if n == 0 {
+		return nil
+	}
+	out := make([]float64, n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			out[i] += net.Cap[i][j]
+		}
+	}
+	rank := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+	for it := 0; it < 100; it++ {
+		next := make([]float64, n)
+		dangling := 0.0
+		for i := 0; i < n; i++ {
+			if out[i] == 0 {
+				dangling += rank[i]
+				continue
+			}
+			for j := 0; j < n; j++ {
+				if net.Cap[i][j] > 0 {
+					next[j] += damping * rank[i] * net.Cap[i][j] / out[i]
+				}
+			}
+		}
+		diff := 0.0
+		for j := range next {
+			next[j] += (1-damping)/float64(n) + damping*dangling/float64(n)
+			diff += math.Abs(next[j] - rank[j])
+		}
+		rank = next
+		if diff < 1e-10 {
+			break
+		}
+	}
+	return rank
+}
+
+// flowCentrality is the mean, over every source/sink pair with a positive
+// maximum flow, of the share of that flow lost when the region is removed:
+// 1 means every unit between the pair has to pass through it.
+func flowCentrality(net *flowNetwork) []float64 {
+	n := len(net.Nodes)
+	scores := make([]float64, n)
+	pairs := 0
+	for s := 0; s < n; s++ {
+		for t := 0; t < n; t++ {
+			if s == t {
+				continue
+			}
+			full, _, _ := net.maxFlow(s, t, nil)
+			if full <= 0 {
+				continue
+			}
+			pairs++
+			for v := 0; v < n; v++ {
+				if v == s || v == t {
+					continue
+				}
+				without, _, _ := net.maxFlow(s, t, map[int]bool{v: true})
+				scores[v] += (full - without) / full
+			}
+		}
+	}
+	if pairs > 0 {
+		for i := range scores {
+			scores[i] /= float64(pairs)
+		}
+	}
+	return scores
+}
+
+// articulationAndBridges runs Tarjan's low-link search on the undirected
+// flow graph.
+func articulationAndBridges(net *flowNetwork) ([]bool, []graphBridge) {
+	n := len(net.Nodes)
+	adj := make([][]int, n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i != j && (net.Cap[i][j] > 0 || net.Cap[j][i] > 0) {
+				adj[i] = append(adj[i], j)
+			}
+		}
+	}
+	disc := make([]int, n)
+	low := make([]int, n)
+	articulation := make([]bool, n)
+	bridges := make([]graphBridge, 0)
+	timer := 0
+	var visit func(u, parent int)
+	visit = func(u, parent int) {
+		timer++
+		disc[u], low[u] = timer, timer
+		children := 0
+		for _, v := range adj[u] {
+			if v == parent {
+				continue
+			}
+			if disc[v] > 0 {
+				low[u] = min(low[u], disc[v])
+				continue
+			}
+			children++
+			visit(v, u)
+			low[u] = min(low[u], low[v])
+			if parent >= 0 && low[v] >= disc[u] {
+				articulation[u] = true
+			}
+			if low[v] > disc[u] {
+				a, b := u, v
+				if net.Cap[a][b] == 0 {
+					a, b = b, a
+				}
+				bridges = append(bridges, graphBridge{Source: net.Nodes[a], Target: net.Nodes[b], Weight: round6(net.Cap[u][v] + net.Cap[v][u])})
+			}
+		}
+		if parent < 0 && children > 1 {
+			articulation[u] = true
+		}
+	}
+	for i := 0; i < n; i++ {
+		if disc[i] == 0 {
+			visit(i, -1)
+		}
+	}
+	sort.Slice(bridges, func(i, j int) bool {
+		if bridges[i].Source != bridges[j].Source {
+			return bridges[i].Source < bridges[j].Source
+		}
+		return bridges[i].Target < bridges[j].Target
+	})
+	return articulation, bridges
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,542 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Region disruption scopes. Production cuts travel through the network:
+// the region passes the lost supply on to its export flows in proportion to
+// exports / (consumption + exports) and keeps the rest as a domestic gap.
+var disruptionScopes = []string{"exports", "imports", "production", "all"}
+
+const (
+	defaultSurge         = 0.1
+	maxPropagationRounds = 100
+	propagationEpsilon   = 1e-9
+)
+
+// disruption scales one flow, or the flows/production of one region, by
+// 1 + Change; -1 removes it entirely.
+type disruption struct {
+	ResourceID string  `json:"resourceId"`
+	RegionID   string  `json:"regionId,omitempty"`
+	FlowID     string  `json:"flowId,omitempty"`
+	Scope      string  `json:"scope,omitempty"`
+	Change     float64 `json:"change"`
+}
+
+// disruptionScenario is the replayable definition of a simulation. The ID is
+// derived from the definition, so saving the same scenario twice is a no-op.
+type disruptionScenario struct {
+	ID             string       `json:"id"`
+	Name           string       `json:"name,omitempty"`
+	Year           int          `json:"year,omitempty"`
+	Disruptions    []disruption `json:"disruptions"`
+	Redistribution string       `json:"redistribution"`
+	Surge          float64      `json:"surge"`
+	SavedAt        string       `json:"savedAt,omitempty"`
+}
+
+type regionImpact struct {
+	RegionID       string   `json:"regionId"`
+	RegionName     string   `json:"regionName"`
+	Consumption    float64  `json:"consumption"`
+	ProductionLoss float64  `json:"productionLoss"`
+	ImportLoss     float64  `json:"importLoss"`
+	PassedOn       float64  `json:"passedOn"`
+	GapBefore      float64  `json:"gapBeforeRedistribution"`
+	Received       float64  `json:"received"`
+	Gap            float64  `json:"gap"`
+	GapShare       *float64 `json:"gapShare"`
+}
+
+type flowImpact struct {
+	ID       string   `json:"id"`
+	Source   string   `json:"source"`
+	Target   string   `json:"target"`
+	Baseline float64  `json:"baseline"`
+	Scenario float64  `json:"scenario"`
+	Change   float64  `json:"change"`
+	Causes   []string `json:"causes"`
+}
+
+type redistributedFlow struct {
+	Source string  `json:"source"`
+	Target string  `json:"target"`
+	Volume float64 `json:"volume"`
+}
+
+type riskSnapshot struct {
+	Score      float64            `json:"score"`
+	HHI        float64            `json:"hhi"`
+	Components map[string]float64 `json:"components"`
+}
+
+type regionRiskDelta struct {
+	RegionID string  `json:"regionId"`
+	Baseline float64 `json:"baseline"`
+	Scenario float64 `json:"scenario"`
+	Delta    float64 `json:"delta"`
+}
+
+type riskDelta struct {
+	Baseline riskSnapshot      `json:"baseline"`
+	Scenario riskSnapshot      `json:"scenario"`
+	Delta    riskSnapshot      `json:"delta"`
+	Regions  []regionRiskDelta `json:"regions"`
+}
+
+type disruptionResult struct {
+	ResourceID    string              `json:"resourceId"`
+	Year          int                 `json:"year"`
+	Unit          string              `json:"unit"`
+	Totals        map[string]float64  `json:"totals"`
+	Rounds        int                 `json:"propagationRounds"`
+	Regions       []regionImpact      `json:"regions"`
+	AffectedFlows []flowImpact        `json:"affectedFlows"`
+	Redistributed []redistributedFlow `json:"redistributedFlows"`
+	RiskBefore    *riskDelta          `json:"riskBeforeRedistribution"`
+	Risk          *riskDelta          `json:"risk"`
+}
+
+var (
+	scenarioMu     sync.Mutex
+	savedScenarios = map[string]disruptionScenario{}
+)
+
+// simulateDisruption runs a scenario given inline or by the ID of a saved one.
+// Saved scenarios live as long as the component instance.
+func simulateDisruption(args map[string]any) (any, error) {
+	var sc disruptionScenario
+	saved := false
+	if id, _ := args["scenario_id"].(string); id != "" {
+		scenarioMu.Lock()
+		s, ok := savedScenarios[id]
+		scenarioMu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("scenario not found: %s", id)
+		}
+		sc, saved = s, true
+	} else {
+		var err error
+		if sc, err = scenarioFromArgs(args); err != nil {
+			return nil, err
+		}
+		if save, _ := args["save"].(bool); save {
+			scenarioMu.Lock()
+			if existing, ok := savedScenarios[sc.ID]; ok {
+				sc = existing
+			} else {
+				sc.SavedAt = time.Now().UTC().Format(time.RFC3339)
+				savedScenarios[sc.ID] = sc
+			}
+			scenarioMu.Unlock()
+			saved = true
+		}
+	}
+
+	ids := make([]string, 0)
+	for _, d := range sc.Disruptions {
+		if !containsString(ids, d.ResourceID) {
+			ids = append(ids, d.ResourceID)
+		}
+	}
+	sort.Strings(ids)
+	results := make([]disruptionResult, 0, len(ids))
+	for _, id := range ids {
+		results = append(results, runDisruption(sc, id))
+	}
+	return map[string]any{"scenario": sc, "saved": saved, "results": results, "count": len(results)}, nil
+}
+
+// listScenarios returns the saved scenario definitions, oldest first.
+func listScenarios() []disruptionScenario {
+	scenarioMu.Lock()
+	defer scenarioMu.Unlock()
+	out := make([]disruptionScenario, 0, len(savedScenarios))
+	for _, sc := range savedScenarios {
+		out = append(out, sc)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].SavedAt != out[j].SavedAt {
+			return out[i].SavedAt < out[j].SavedAt
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+func scenarioFromArgs(args map[string]any) (disruptionScenario, error) {
+	sc := disruptionScenario{Year: toInt(args["year"]), Surge: defaultSurge, Disruptions: []disruption{}}
+	sc.Name, _ = args["name"].(string)
+	sc.Redistribution, _ = args["redistribution"].(string)
+	if sc.Redistribution == "" {
+		sc.Redistribution = "proportional"
+	}
+	if sc.Redistribution != "proportional" && sc.Redistribution != "none" {
+		return sc, fmt.Errorf("redistribution must be proportional or none")
+	}
+	if v, ok := args["surge"].(float64); ok {
+		if v < 0 {
+			return sc, fmt.Errorf("surge must not be negative")
+		}
+		sc.Surge = v
+	}
+	defaultResource, _ := args["resource_id"].(string)
+	raw, _ := args["disruptions"].([]any)
+	if len(raw) == 0 {
+		return sc, fmt.Errorf("disruptions is required")
+	}
+	for i, item := range raw {
+		m, _ := item.(map[string]any)
+		d := disruption{Change: -1}
+		d.ResourceID, _ = m["resource_id"].(string)
+		d.RegionID, _ = m["region_id"].(string)
+		d.FlowID, _ = m["flow_id"].(string)
+		d.Scope, _ = m["scope"].(string)
+		if v, ok := m["change"].(float64); ok {
+			d.Change = v
+		}
+		if (d.RegionID == "") == (d.FlowID == "") {
+			return sc, fmt.Errorf("disruptions[%d]: set exactly one of region_id or flow_id", i)
+		}
+		if d.Change < -1 || d.Change > 0 {
+			return sc, fmt.Errorf("disruptions[%d]: change must be between -1 and 0", i)
+		}
+		if d.ResourceID == "" {
+			d.ResourceID = defaultResource
+		}
+		if d.FlowID != "" {
+			var flow *ResourceFlow
+			observed := settledFlows()
+			for j := range observed {
+				if observed[j].ID == d.FlowID {
+					flow = &observed[j]
+				}
+			}
+			if flow == nil {
+				for _, f := range flows {
+					if f.ID == d.FlowID {
+						return sc, fmt.Errorf("disruptions[%d]: flow %s is superseded by the %s's mirror record", i, d.FlowID, defaultMirrorPreference)
+					}
+				}
+				return sc, fmt.Errorf("disruptions[%d]: flow not found: %s", i, d.FlowID)
+			}
+			if d.ResourceID != "" && d.ResourceID != flow.ResourceID {
+				return sc, fmt.Errorf("disruptions[%d]: flow %s carries %s, not %s", i, d.FlowID, flow.ResourceID, d.ResourceID)
+			}
+			d.ResourceID, d.Scope = flow.ResourceID, ""
+		} else {
+			if d.Scope == "" {
+				d.Scope = "exports"
+			}
+			if !containsString(disruptionScopes, d.Scope) {
+				return sc, fmt.Errorf("disruptions[%d]: scope must be one of %v", i, disruptionScopes)
+			}
+			if d.ResourceID == "" {
+				return sc, fmt.Errorf("disruptions[%d]: resource_id is required for region disruptions", i)
+			}
+		}
+		if resourceByID(d.ResourceID) == nil {
+			return sc, fmt.Errorf("disruptions[%d]: resource not found: %s", i, d.ResourceID)
+		}
+		sc.Disruptions = append(sc.Disruptions, d)
+	}
+	raw2, _ := json.Marshal(struct {
+		Name, Redistribution string
+		Year                 int
+		Surge                float64
+		Disruptions          []disruption
+	}{sc.Name, sc.Redistribution, sc.Year, sc.Surge, sc.Disruptions})
+	sum := sha256.Sum256(raw2)
+	sc.ID = "scenario-" + hex.EncodeToString(sum[:])[:12]
+	return sc, nil
+}
+
+// runDisruption applies the scenario's disruptions of one resource, lets the
+// lost supply propagate downstream, then covers gaps from the spare capacity
+// of undisrupted producers: their surplus (production − consumption −
+// exports) plus Surge × production, shared in proportion to each region's gap.
+func runDisruption(sc disruptionScenario, resourceID string) disruptionResult {
+	year := sc.Year
+	if year == 0 {
+		year = latestDataYear(resourceID)
+	}
+	res := disruptionResult{ResourceID: resourceID, Year: year, Regions: []regionImpact{}, AffectedFlows: []flowImpact{}, Redistributed: []redistributedFlow{}}
+	if r := resourceByID(resourceID); r != nil {
+		res.Unit = r.Unit
+	}
+	stats := map[string]RegionStats{}
+	for _, s := range resourceStats[resourceID] {
+		if s.Year == year {
+			stats[s.RegionID] = s
+		}
+	}
+	base := make([]ResourceFlow, 0)
+	for _, f := range settledFlows() {
+		if f.ResourceID == resourceID && f.Year == year && f.SourceRegion != f.TargetRegion {
+			base = append(base, f)
+		}
+	}
+	vol := make([]float64, len(base))
+	causes := make([][]string, len(base))
+	for i, f := range base {
+		vol[i] = f.Volume
+	}
+
+	prodFactor := map[string]float64{}
+	prodLoss, importLoss, exportLoss := map[string]float64{}, map[string]float64{}, map[string]float64{}
+	pending, retained, passedOn := map[string]float64{}, map[string]float64{}, map[string]float64{}
+	disrupted := map[string]bool{}
+	direct, propagated := 0.0, 0.0
+	cut := func(i int, amount float64, cause string) {
+		if amount <= 0 {
+			return
+		}
+		vol[i] -= amount
+		importLoss[base[i].TargetRegion] += amount
+		exportLoss[base[i].SourceRegion] += amount
+		pending[base[i].TargetRegion] += amount
+		if !containsString(causes[i], cause) {
+			causes[i] = append(causes[i], cause)
+		}
+		if cause == "propagation" {
+			propagated += amount
+		} else {
+			direct += amount
+		}
+	}
+
+	for _, d := range sc.Disruptions {
+		if d.ResourceID != resourceID {
+			continue
+		}
+		keep := 1 + d.Change
+		if d.FlowID != "" {
+			for i, f := range base {
+				if f.ID == d.FlowID {
+					cut(i, vol[i]*(1-keep), "disruption")
+				}
+			}
+			continue
+		}
+		disrupted[d.RegionID] = true
+		exported := 0.0
+		for i, f := range base {
+			if (f.SourceRegion == d.RegionID && (d.Scope == "exports" || d.Scope == "all")) ||
+				(f.TargetRegion == d.RegionID && (d.Scope == "imports" || d.Scope == "all")) {
+				if f.SourceRegion == d.RegionID {
+					exported += vol[i] * (1 - keep)
+				}
+				cut(i, vol[i]*(1-keep), "disruption")
+			}
+		}
+		if s, ok := stats[d.RegionID]; ok && (d.Scope == "production" || d.Scope == "all") {
+			factor, seen := prodFactor[d.RegionID]
+			if !seen {
+				factor = 1
+			}
+			loss := s.Production * factor * (1 - keep)
+			prodFactor[d.RegionID] = factor * keep
+			prodLoss[d.RegionID] += loss
+			// With scope all the export flows are already cut; only the
+			// remainder of the production loss falls on the region itself.
+			domestic := math.Max(0, loss-exported)
+			pending[d.RegionID] += domestic
+			direct += domestic
+		}
+	}
+
+	// passShare is the part of a supply loss a region hands on to its
+	// importers; regions without stats pass on up to their flow exports.
+	passShare := func(region string) float64 {
+		if s, ok := stats[region]; ok {
+			if s.Export+s.Consumption <= 0 {
+				return 0
+			}
+			return s.Export / (s.Export + s.Consumption)
+		}
+		return 1
+	}
+	for res.Rounds < maxPropagationRounds {
+		moved := false
+		for _, region := range sortedKeys(pending) {
+			p := pending[region]
+			if p <= propagationEpsilon {
+				continue
+			}
+			moved = true
+			pending[region] = 0
+			out := 0.0
+			for i, f := range base {
+				if f.SourceRegion == region {
+					out += vol[i]
+				}
+			}
+			passed := math.Min(p*passShare(region), out)
+			if passed > 0 {
+				for i, f := range base {
+					if f.SourceRegion == region {
+						cut(i, passed*vol[i]/out, "propagation")
+					}
+				}
+			}
+			passedOn[region] += passed
+			retained[region] += p - passed
+		}
+		if !moved {
+			break
+		}
+		res.Rounds++
+	}
+	for region, p := range pending {
+		retained[region] += p
+	}
+
+	received := map[string]float64{}
+	spare := map[string]float64{}
+	spareTotal, gapTotal := 0.0, 0.0
+	for _, g := range retained {
+		gapTotal += g
+	}
+	if sc.Redistribution == "proportional" && gapTotal > propagationEpsilon {
+		// Regions short of supply themselves keep any surge at home, so they
+		// neither supply the pool nor cover their own gap from it.
+		for id, s := range stats {
+			if disrupted[id] || retained[id] > propagationEpsilon || s.Production <= 0 {
+				continue
+			}
+			spare[id] = math.Max(0, s.Production-s.Consumption-s.Export) + sc.Surge*s.Production
+			spareTotal += spare[id]
+		}
+		covered := math.Min(spareTotal, gapTotal)
+		for _, region := range sortedKeys(retained) {
+			received[region] = retained[region] * covered / gapTotal
+		}
+		for _, supplier := range sortedKeys(spare) {
+			for _, region := range sortedKeys(received) {
+				if v := received[region] * spare[supplier] / spareTotal; v > propagationEpsilon {
+					res.Redistributed = append(res.Redistributed, redistributedFlow{Source: supplier, Target: region, Volume: round6(v)})
+				}
+			}
+		}
+	}
+
+	regions := map[string]bool{}
+	for _, m := range []map[string]float64{prodLoss, importLoss, retained, received} {
+		for id := range m {
+			regions[id] = true
+		}
+	}
+	gapSum, receivedSum := 0.0, 0.0
+	for _, id := range sortedKeys(regions) {
+		ri := regionImpact{
+			RegionID: id, RegionName: regionName(id), Consumption: stats[id].Consumption,
+			ProductionLoss: round6(prodLoss[id]), ImportLoss: round6(importLoss[id]), PassedOn: round6(passedOn[id]),
+			GapBefore: round6(retained[id]), Received: round6(received[id]),
+			Gap: round6(math.Max(0, retained[id]-received[id])),
+		}
+		if ri.Consumption > 0 {
+			share := round6(ri.Gap / ri.Consumption)
+			ri.GapShare = &share
+		}
+		gapSum += ri.Gap
+		receivedSum += received[id]
+		res.Regions = append(res.Regions, ri)
+	}
+	sort.SliceStable(res.Regions, func(i, j int) bool { return res.Regions[i].Gap > res.Regions[j].Gap })
+	for i, f := range base {
+		if len(causes[i]) == 0 {
+			continue
+		}
+		res.AffectedFlows = append(res.AffectedFlows, flowImpact{
+			ID: f.ID, Source: f.SourceRegion, Target: f.TargetRegion,
+			Baseline: f.Volume, Scenario: round6(vol[i]), Change: round6(vol[i] - f.Volume), Causes: causes[i],
+		})
+	}
+	res.Totals = map[string]float64{
+		"directLoss":     round6(direct),
+		"propagatedLoss": round6(propagated),
+		"gapBefore":      round6(gapTotal),
+		"spareCapacity":  round6(spareTotal),
+		"redistributed":  round6(receivedSum),
+		"unmet":          round6(gapSum),
+	}
+	res.RiskBefore = disruptionRiskDelta(resourceID, year, stats, base, vol, prodFactor, importLoss, exportLoss, nil)
+	res.Risk = disruptionRiskDelta(resourceID, year, stats, base, vol, prodFactor, importLoss, exportLoss, res.Redistributed)
+	return res
+}
+
+// disruptionRiskDelta scores the resource on the baseline data and on the
+// scenario's stats and flows. Redistributed volume counts as added output of
+// its supplier; consumption is left at baseline demand.
+func disruptionRiskDelta(resourceID string, year int, stats map[string]RegionStats, base []ResourceFlow, vol []float64,
+	prodFactor, importLoss, exportLoss map[string]float64, redistributed []redistributedFlow) *riskDelta {
+	before, ok := resourceRiskFor(resourceID, year, resourceStats[resourceID], settledFlows())
+	if !ok {
+		return nil
+	}
+	scenarioFlows := make([]ResourceFlow, 0, len(base)+len(redistributed))
+	for i, f := range base {
+		f.Volume = vol[i]
+		scenarioFlows = append(scenarioFlows, f)
+	}
+	imports, exports, added := map[string]float64{}, map[string]float64{}, map[string]float64{}
+	for _, r := range redistributed {
+		added[r.Source] += r.Volume
+		scenarioFlows = append(scenarioFlows, ResourceFlow{ID: "redistribution:" + r.Source + "-" + r.Target, ResourceID: resourceID, SourceRegion: r.Source, TargetRegion: r.Target, Year: year, Volume: r.Volume})
+		exports[r.Source] += r.Volume
+		imports[r.Target] += r.Volume
+	}
+	scenarioStats := make([]RegionStats, 0, len(stats))
+	for _, id := range sortedKeys(stats) {
+		s := stats[id]
+		if f, ok := prodFactor[id]; ok {
+			s.Production *= f
+		}
+		s.Production += added[id]
+		s.Import = math.Max(0, s.Import-importLoss[id]+imports[id])
+		s.Export = math.Max(0, s.Export-exportLoss[id]+exports[id])
+		scenarioStats = append(scenarioStats, s)
+	}
+	after, _ := resourceRiskFor(resourceID, year, scenarioStats, scenarioFlows)
+
+	snapshot := func(r resourceRisk) riskSnapshot {
+		return riskSnapshot{Score: r.Score, HHI: r.HHI, Components: r.Components}
+	}
+	d := &riskDelta{Baseline: snapshot(before), Scenario: snapshot(after), Regions: []regionRiskDelta{}}
+	d.Delta = riskSnapshot{Score: round6(after.Score - before.Score), HHI: round6(after.HHI - before.HHI), Components: map[string]float64{}}
+	for k, v := range after.Components {
+		d.Delta.Components[k] = round6(v - before.Components[k])
+	}
+	for k, v := range before.Components {
+		if _, ok := after.Components[k]; !ok {
+			d.Delta.Components[k] = round6(-v)
+		}
+	}
+	scores := map[string]float64{}
+	for _, r := range after.Regions {
+		scores[r.RegionID] = r.Score
+	}
+	for _, r := range before.Regions {
+		d.Regions = append(d.Regions, regionRiskDelta{RegionID: r.RegionID, Baseline: r.Score, Scenario: scores[r.RegionID], Delta: round6(scores[r.RegionID] - r.Score)})
+	}
+	sort.SliceStable(d.Regions, func(i, j int) bool { return d.Regions[i].Delta > d.Regions[j].Delta })
+	return d
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// expr is a parsed arithmetic expression from a system model equation:
+// numbers, names, + - * / ^, parentheses and the functions in exprFuncs.
+type expr struct {
+	op   string // num, var, neg, + - * / ^, call
+	num  float64
+	name string
+	args []*expr
+}
+
+// exprFuncs maps function names to their arity; -1 means one or more.
+var exprFuncs = map[string]int{
+	"min": -1, "max": -1, "abs": 1, "exp": 1, "ln": 1, "log10": 1, "sqrt": 1,
+	"pow": 2, "clamp": 3, "step": 2,
+}
+
+// parseExpr parses src with the usual precedence: unary minus binds looser
+// than ^, so -2^2 is -4, and ^ is right-associative.
+func parseExpr(src string) (*expr, error) {
+	p := &exprParser{src: src}
+	p.next()
+	e, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != "" {
+		return nil, fmt.Errorf("unexpected %q at %d", p.tok, p.start)
+	}
+	return e, nil
+}
+
+type exprParser struct {
+	src        string
+	pos, start int
+	tok        string
+}
+
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\n') {
+		p.pos++
+	}
+	p.start = p.pos
+	if p.pos >= len(p.src) {
+		p.tok = ""
+		return
+	}
+	c := p.src[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		// exponent, e.g. 1e-3
+		if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			q := p.pos + 1
+			if q < len(p.src) && (p.src[q] == '+' || p.src[q] == '-') {
+				q++
+			}
+			if q < len(p.src) && isDigit(p.src[q]) {
+				for q < len(p.src) && isDigit(p.src[q]) {
+					q++
+				}
+				p.pos = q
+			}
+		}
+	case isIdentStart(c):
+		for p.pos < len(p.src) && (isIdentStart(p.src[p.pos]) || isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+	default:
+		p.pos++
+	}
+	p.tok = p.src[p.start:p.pos]
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isIdentStart(c byte) bool { return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+
+func (p *exprParser) sum() (*expr, error) {
+	left, err := p.product()
+	for err == nil && (p.tok == "+" || p.tok == "-") {
+		op := p.tok
+		p.next()
+		var right *expr
+		if right, err = p.product(); err == nil {
+			left = &expr{op: op, args: []*expr{left, right}}
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) product() (*expr, error) {
+	left, err := p.unary()
+	for err == nil && (p.tok == "*" || p.tok == "/") {
+		op := p.tok
+		p.next()
+		var right *expr
+		if right, err = p.unary(); err == nil {
+			left = &expr{op: op, args: []*expr{left, right}}
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) unary() (*expr, error) {
+	switch p.tok {
+	case "-":
+		p.next()
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &expr{op: "neg", args: []*expr{e}}, nil
+	case "+":
+		p.next()
+		return p.unary()
+	}
+	return p.power()
+}
+
+func (p *exprParser) power() (*expr, error) {
+	base, err := p.primary()
+	if err != nil || p.tok != "^" {
+		return base, err
+	}
+	p.next()
+	exp, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return &expr{op: "^", args: []*expr{base, exp}}, nil
+}
+
+func (p *exprParser) primary() (*expr, error) {
+	tok, at := p.tok, p.start
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case tok == "(":
+		p.next()
+		e, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok != ")" {
+			return nil, fmt.Errorf("missing ) at %d", p.start)
+		}
+		p.next()
+		return e, nil
+	case isDigit(tok[0]) || tok[0] == '.':
+		v, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q at %d", tok, at)
+		}
+		p.next()
+		return &expr{op: "num", num: v}, nil
+	case isIdentStart(tok[0]):
+		p.next()
+		if p.tok != "(" {
+			return &expr{op: "var", name: tok}, nil
+		}
+		arity, ok := exprFuncs[tok]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %s at %d", tok, at)
+		}
+		p.next()
+		call := &expr{op: "call", name: tok}
+		for p.tok != ")" {
+			arg, err := p.sum()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.tok == "," {
+				p.next()
+			} else if p.tok != ")" {
+				return nil, fmt.Errorf("expected , or ) at %d", p.start)
+			}
+		}
+		p.next()
+		if (arity < 0 && len(call.args) == 0) || (arity >= 0 && len(call.args) != arity) {
+			return nil, fmt.Errorf("%s takes %s arguments, got %d", tok, arityText(arity), len(call.args))
+		}
+		return call, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", tok, at)
+}
+
+func arityText(n int) string {
+	if n < 0 {
+		return "one or more"
+	}
+	return strconv.Itoa(n)
+}
+
+// names returns the variable names the expression reads, sorted.
+func (e *expr) names() []string {
+	set := map[string]bool{}
+	var walk func(*expr)
+	walk = func(x *expr) {
+		if x.op == "var" {
+			set[x.name] = true
+		}
+		if x.op == "call" && x.name == "step" {
+			set["time"] = true
+		}
+		for _, a := range x.args {
+			walk(a)
+		}
+	}
+	walk(e)
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// eval evaluates the expression, resolving names with lookup.
+func (e *expr) eval(lookup func(string) float64) float64 {
+	arg := func(i int) float64 { return e.args[i].eval(lookup) }
+	switch e.op {
+	case "num":
+		return e.num
+	case "var":
+		return lookup(e.name)
+	case "neg":
+		return -arg(0)
+	case "+":
+		return arg(0) + arg(1)
+	case "-":
+		return arg(0) - arg(1)
+	case "*":
+		return arg(0) * arg(1)
+	case "/":
+		return arg(0) / arg(1)
+	case "^":
+		return math.Pow(arg(0), arg(1))
+	}
+	switch e.name {
+	case "min", "max":
+		v := arg(0)
+		for i := 1; i < len(e.args); i++ {
+			if e.name == "min" {
+				v = math.Min(v, arg(i))
+			} else {
+				v = math.Max(v, arg(i))
+			}
+		}
+		return v
+	case "abs":
+		return math.Abs(arg(0))
+	case "exp":
+		return math.Exp(arg(0))
+	case "ln":
+		return math.Log(arg(0))
+	case "log10":
+		return math.Log10(arg(0))
+	case "sqrt":
+		return math.Sqrt(arg(0))
+	case "pow":
+		return math.Pow(arg(0), arg(1))
+	case "clamp":
+		return math.Max(arg(1), math.Min(arg(2), arg(0)))
+	case "step":
+		// step(height, start) is 0 before start and height from then on.
+		if lookup("time") >= arg(1) {
+			return arg(0)
+		}
+		return 0
+	}
+	return math.NaN()
+}
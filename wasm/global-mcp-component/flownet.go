@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// flowNetwork is the directed region graph of ResourceFlow edges for one
+// resource (or all resources) and year, with parallel flows summed into a
+// capacity matrix.
+type flowNetwork struct {
+	ResourceID string
+	Year       int
+	WeightBy   string
+	Nodes      []string
+	Index      map[string]int
+	Cap        [][]float64
+}
+
+// flowNetworkFromArgs reads resource_id, year and weight_by. Across all
+// resources only value weights are comparable, so volume is rejected there.
+func flowNetworkFromArgs(args map[string]any) (*flowNetwork, error) {
+	resourceID, _ := args["resource_id"].(string)
+	if resourceID != "" && resourceByID(resourceID) == nil {
+		return nil, fmt.Errorf("resource not found: %s", resourceID)
+	}
+	weightBy, _ := args["weight_by"].(string)
+	switch {
+	case weightBy == "" && resourceID == "":
+		weightBy = "value"
+	case weightBy == "":
+		weightBy = "volume"
+	case weightBy != "volume" && weightBy != "value":
+		return nil, fmt.Errorf("weight_by must be volume or value")
+	case weightBy == "volume" && resourceID == "":
+		return nil, fmt.Errorf("weight_by volume needs a resource_id: volumes of different resources are not comparable")
+	}
+	year := toInt(args["year"])
+	if year == 0 {
+		year = latestDataYear(resourceID)
+	}
+	return buildFlowNetwork(resourceID, year, weightBy), nil
+}
+
+func buildFlowNetwork(resourceID string, year int, weightBy string) *flowNetwork {
+	net := &flowNetwork{ResourceID: resourceID, Year: year, WeightBy: weightBy, Index: map[string]int{}}
+	selected := make([]ResourceFlow, 0)
+	seen := map[string]bool{}
+	for _, f := range settledFlows() {
+		if (resourceID != "" && f.ResourceID != resourceID) || f.Year != year || f.SourceRegion == f.TargetRegion {
+			continue
+		}
+		selected = append(selected, f)
+		seen[f.SourceRegion] = true
+		seen[f.TargetRegion] = true
+	}
+	for id := range seen {
+		net.Nodes = append(net.Nodes, id)
+	}
+	sort.Strings(net.Nodes)
+	for i, id := range net.Nodes {
+		net.Index[id] = i
+	}
+	net.Cap = make([][]float64, len(net.Nodes))
+	for i := range net.Cap {
+		net.Cap[i] = make([]float64, len(net.Nodes))
+	}
+	for _, f := range selected {
+		w := f.Volume
+		if weightBy == "value" {
+			w = f.Value
+		}
+		net.Cap[net.Index[f.SourceRegion]][net.Index[f.TargetRegion]] += math.Max(w, 0)
+	}
+	return net
+}
+
+// total is the sum of all edge weights.
+func (n *flowNetwork) total() float64 {
+	sum := 0.0
+	for _, row := range n.Cap {
+		for _, w := range row {
+			sum += w
+		}
+	}
+	return sum
+}
+
+// maxFlow runs Edmonds–Karp from s to t over a copy of the capacities,
+// skipping the nodes in exclude; edges touching them carry no flow. It returns the flow value, the per-edge
+// flow matrix and the nodes reachable from s in the final residual graph
+// (the source side of a minimum cut).
+func (n *flowNetwork) maxFlow(s, t int, exclude map[int]bool) (float64, [][]float64, []bool) {
+	size := len(n.Nodes)
+	capacity := make([][]float64, size)
+	for i := range capacity {
+		capacity[i] = append([]float64(nil), n.Cap[i]...)
+		if exclude[i] {
+			for j := range capacity[i] {
+				capacity[i][j] = 0
+			}
+		}
+	}
+	for i := range capacity {
+		for j := range exclude {
+			capacity[i][j] = 0
+		}
+	}
+	residual := make([][]float64, size)
+	for i := range residual {
+		residual[i] = append([]float64(nil), capacity[i]...)
+	}
+
+	total := 0.0
+	for {
+		parent := make([]int, size)
+		for i := range parent {
+			parent[i] = -1
+		}
+		parent[s] = s
+		queue := []int{s}
+		for len(queue) > 0 && parent[t] < 0 {
+			u := queue[0]
+			queue = queue[1:]
+			for v := 0; v < size; v++ {
+				if parent[v] < 0 && residual[u][v] > 1e-12 {
+					parent[v] = u
+					queue = append(queue, v)
+				}
+			}
+		}
+		if parent[t] < 0 {
+			break
+		}
+		bottleneck := math.Inf(1)
+		for v := t; v != s; v = parent[v] {
+			bottleneck = math.Min(bottleneck, residual[parent[v]][v])
+		}
+		for v := t; v != s; v = parent[v] {
+			residual[parent[v]][v] -= bottleneck
+			residual[v][parent[v]] += bottleneck
+		}
+		total += bottleneck
+	}
+
+	reach := make([]bool, size)
+	reach[s] = true
+	queue := []int{s}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		for v := 0; v < size; v++ {
+			if !reach[v] && residual[u][v] > 1e-12 {
+				reach[v] = true
+				queue = append(queue, v)
+			}
+		}
+	}
+	flow := make([][]float64, size)
+	for i := range flow {
+		flow[i] = make([]float64, size)
+		for j := range flow[i] {
+			if f := capacity[i][j] - residual[i][j]; capacity[i][j] > 0 && f > 1e-12 {
+				flow[i][j] = f
+			}
+		}
+	}
+	return total, flow, reach
+}
+
+// regionName returns a region's display name from any resource's stats.
+func regionName(regionID string) string {
+	for _, id := range sortedStatKeys() {
+		for _, s := range resourceStats[id] {
+			if s.RegionID == regionID {
+				return s.RegionName
+			}
+		}
+	}
+	return strings.ToUpper(regionID)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// regionCentroids locates regions that appear in flows but have no
+// RegionStats of their own (aggregates such as the EU).
+var regionCentroids = map[string][2]float64{
+	"eu": {50.1, 9.7},
+}
+
+// regionLocation returns the lat/lng of a region from any resource's stats,
+// falling back to regionCentroids.
+func regionLocation(regionID string) (lat, lng float64, ok bool) {
+	for _, id := range sortedStatKeys() {
+		for _, s := range resourceStats[id] {
+			if s.RegionID == regionID {
+				return s.Lat, s.Lng, true
+			}
+		}
+	}
+	if c, found := regionCentroids[regionID]; found {
+		return c[0], c[1], true
+	}
+	return 0, 0, false
+}
+
+func sortedStatKeys() []string {
+	keys := make([]string, 0, len(resourceStats))
+	for k := range resourceStats {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// latestStatsYear returns the most recent year with stats for a resource.
+func latestStatsYear(resourceID string) int {
+	year := 0
+	for _, s := range resourceStats[resourceID] {
+		if s.Year > year {
+			year = s.Year
+		}
+	}
+	return year
+}
+
+// buildGeoJSON returns a FeatureCollection with one Point per region carrying
+// its stats, and one LineString (or great-circle arc) per flow.
+func buildGeoJSON(resourceID string, year int, includeFlows, greatCircle bool, segments int) (map[string]any, error) {
+	if resourceID == "" {
+		return nil, fmt.Errorf("resource_id is required")
+	}
+	if resourceByID(resourceID) == nil {
+		return nil, fmt.Errorf("resource not found: %s", resourceID)
+	}
+	if year == 0 {
+		year = latestStatsYear(resourceID)
+	}
+	if segments <= 0 {
+		segments = 32
+	}
+	if segments > 256 {
+		segments = 256
+	}
+
+	features := make([]any, 0)
+	for _, s := range resourceStats[resourceID] {
+		if s.Year != year {
+			continue
+		}
+		features = append(features, map[string]any{
+			"type":     "Feature",
+			"id":       "region:" + s.RegionID,
+			"geometry": map[string]any{"type": "Point", "coordinates": []float64{s.Lng, s.Lat}},
+			"properties": map[string]any{
+				"kind": "region", "resourceId": resourceID, "regionId": s.RegionID, "regionName": s.RegionName,
+				"year": s.Year, "production": s.Production, "consumption": s.Consumption,
+				"export": s.Export, "import": s.Import, "reserve": s.Reserve,
+				"netExport": s.Export - s.Import,
+			},
+		})
+	}
+
+	skipped := make([]string, 0)
+	if includeFlows {
+		for _, f := range settledFlows() {
+			if f.ResourceID != resourceID || f.Year != year {
+				continue
+			}
+			slat, slng, ok1 := regionLocation(f.SourceRegion)
+			tlat, tlng, ok2 := regionLocation(f.TargetRegion)
+			if !ok1 || !ok2 {
+				skipped = append(skipped, f.ID)
+				continue
+			}
+			var geometry map[string]any
+			if greatCircle {
+				geometry = arcGeometry(greatCirclePoints(slat, slng, tlat, tlng, segments))
+			} else {
+				geometry = map[string]any{"type": "LineString", "coordinates": [][]float64{{slng, slat}, {tlng, tlat}}}
+			}
+			features = append(features, map[string]any{
+				"type":     "Feature",
+				"id":       "flow:" + f.ID,
+				"geometry": geometry,
+				"properties": map[string]any{
+					"kind": "flow", "flowId": f.ID, "resourceId": f.ResourceID,
+					"sourceRegion": f.SourceRegion, "targetRegion": f.TargetRegion,
+					"year": f.Year, "volume": f.Volume, "value": f.Value,
+				},
+			})
+		}
+	}
+
+	out := map[string]any{
+		"type":     "FeatureCollection",
+		"features": features,
+		"properties": map[string]any{
+			"resourceId": resourceID, "year": year, "greatCircle": greatCircle,
+		},
+	}
+	if len(skipped) > 0 {
+		out["properties"].(map[string]any)["skippedFlows"] = skipped
+	}
+	return out, nil
+}
+
+// greatCirclePoints interpolates segments+1 points along the great circle
+// between two lat/lng positions, returned as [lng, lat] pairs.
+func greatCirclePoints(lat1, lng1, lat2, lng2 float64, segments int) [][]float64 {
+	rad := math.Pi / 180
+	p1, l1, p2, l2 := lat1*rad, lng1*rad, lat2*rad, lng2*rad
+	d := 2 * math.Asin(math.Sqrt(math.Pow(math.Sin((p2-p1)/2), 2)+math.Cos(p1)*math.Cos(p2)*math.Pow(math.Sin((l2-l1)/2), 2)))
+	if d == 0 {
+		return [][]float64{{lng1, lat1}, {lng2, lat2}}
+	}
+	pts := make([][]float64, 0, segments+1)
+	for i := 0; i <= segments; i++ {
+		f := float64(i) / float64(segments)
+		a := math.Sin((1-f)*d) / math.Sin(d)
+		b := math.Sin(f*d) / math.Sin(d)
+		x := a*math.Cos(p1)*math.Cos(l1) + b*math.Cos(p2)*math.Cos(l2)
+		y := a*math.Cos(p1)*math.Sin(l1) + b*math.Cos(p2)*math.Sin(l2)
+		z := a*math.Sin(p1) + b*math.Sin(p2)
+		lat := math.Atan2(z, math.Sqrt(x*x+y*y)) / rad
+		lng := math.Atan2(y, x) / rad
+		pts = append(pts, []float64{round6(lng), round6(lat)})
+	}
+	return pts
+}
+
+// arcGeometry splits an arc at the antimeridian into a MultiLineString, as
+// RFC 7946 recommends, so map clients don't draw it across the whole globe.
+func arcGeometry(pts [][]float64) map[string]any {
+	parts := [][][]float64{{pts[0]}}
+	for i := 1; i < len(pts); i++ {
+		prev, cur := pts[i-1], pts[i]
+		if math.Abs(cur[0]-prev[0]) > 180 {
+			// interpolate the latitude where the segment meets ±180
+			edge := 180.0
+			if prev[0] < 0 {
+				edge = -180
+			}
+			curShifted := cur[0] + 2*edge
+			t := (edge - prev[0]) / (curShifted - prev[0])
+			lat := round6(prev[1] + t*(cur[1]-prev[1]))
+			last := len(parts) - 1
+			parts[last] = append(parts[last], []float64{edge, lat})
+			parts = append(parts, [][]float64{{-edge, lat}})
+		}
+		last := len(parts) - 1
+		parts[last] = append(parts[last], cur)
+	}
+	if len(parts) == 1 {
+		return map[string]any{"type": "LineString", "coordinates": parts[0]}
+	}
+	return map[string]any{"type": "MultiLineString", "coordinates": parts}
+}
+
+func round6(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Node layers of the resource graph.
+const (
+	nodeRegion       = "region"
+	nodeResource     = "resource"
+	nodeResourceType = "resource_type"
+)
+
+// Edge kinds of the resource graph.
+const (
+	edgeFlow     = "flow"
+	edgeProduces = "produces"
+	edgeConsumes = "consumes"
+	edgeMemberOf = "member_of"
+)
+
+// typeColors is the base color of each resource type; resources of the same
+// type get lighter shades of it.
+var typeColors = map[ResourceType]string{
+	ResourceEnergy:   "#f59e0b",
+	ResourceMineral:  "#8b5cf6",
+	ResourceFood:     "#22c55e",
+	ResourceWater:    "#0ea5e9",
+	ResourceLabor:    "#ec4899",
+	ResourceCapital:  "#64748b",
+	ResourceTech:     "#3b82f6",
+	ResourceMaterial: "#a16207",
+}
+
+const (
+	colorExporter = "#2563eb"
+	colorImporter = "#059669"
+	colorNeutral  = "#94a3b8"
+)
+
+type graphOptions struct {
+	ResourceID   string
+	Year         int
+	WeightBy     string
+	SizeBy       string
+	IncludeTypes bool
+}
+
+func graphOptionsFromArgs(args map[string]any) (graphOptions, error) {
+	opts := graphOptions{Year: toInt(args["year"])}
+	opts.ResourceID, _ = args["resource_id"].(string)
+	opts.WeightBy, _ = args["weight_by"].(string)
+	opts.SizeBy, _ = args["size_by"].(string)
+	opts.IncludeTypes, _ = args["include_types"].(bool)
+	switch opts.WeightBy {
+	case "":
+		opts.WeightBy = "volume"
+	case "volume", "value":
+	default:
+		return opts, fmt.Errorf("weight_by must be volume or value")
+	}
+	switch opts.SizeBy {
+	case "":
+		opts.SizeBy = "throughput"
+	case "production", "consumption", "throughput":
+	default:
+		return opts, fmt.Errorf("size_by must be production, consumption or throughput")
+	}
+	if opts.ResourceID != "" && resourceByID(opts.ResourceID) == nil {
+		return opts, fmt.Errorf("resource not found: %s", opts.ResourceID)
+	}
+	if opts.Year == 0 {
+		opts.Year = latestDataYear(opts.ResourceID)
+	}
+	return opts, nil
+}
+
+func resourceByID(id string) *Resource {
+	for i := range resources {
+		if resources[i].ID == id {
+			return &resources[i]
+		}
+	}
+	return nil
+}
+
+// latestDataYear returns the most recent year with stats or flows for a
+// resource, or for any resource when resourceID is empty.
+func latestDataYear(resourceID string) int {
+	year := 0
+	for id, stats := range resourceStats {
+		if resourceID != "" && id != resourceID {
+			continue
+		}
+		for _, s := range stats {
+			if s.Year > year {
+				year = s.Year
+			}
+		}
+	}
+	for _, f := range settledFlows() {
+		if (resourceID == "" || f.ResourceID == resourceID) && f.Year > year {
+			year = f.Year
+		}
+	}
+	return year
+}
+
+// resourceColors assigns each resource a shade of its type's color.
+func resourceColors() map[string]string {
+	byType := map[ResourceType][]string{}
+	for _, r := range resources {
+		byType[r.Type] = append(byType[r.Type], r.ID)
+	}
+	out := map[string]string{}
+	for t, ids := range byType {
+		sort.Strings(ids)
+		base, ok := typeColors[t]
+		if !ok {
+			base = colorNeutral
+		}
+		for i, id := range ids {
+			out[id] = lighten(base, 0.45*float64(i)/float64(len(ids)))
+		}
+	}
+	return out
+}
+
+// lighten mixes a #rrggbb color with white by f (0..1).
+func lighten(hex string, f float64) string {
+	v, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil || f <= 0 {
+		return hex
+	}
+	mix := func(c uint64) uint64 { return c + uint64(math.Round(float64(255-c)*f)) }
+	return fmt.Sprintf("#%02x%02x%02x", mix(v>>16&0xff), mix(v>>8&0xff), mix(v&0xff))
+}
+
+// scaleSize maps v onto [min, max] by square root of its share of the
+// largest value, so node area tracks the quantity.
+func scaleSize(v, largest, min, max float64) float64 {
+	if largest <= 0 || v <= 0 {
+		return min
+	}
+	return math.Round((min+(max-min)*math.Sqrt(v/largest))*100) / 100
+}
+
+func statMeasure(s RegionStats, sizeBy string) float64 {
+	switch sizeBy {
+	case "production":
+		return s.Production
+	case "consumption":
+		return s.Consumption
+	}
+	return s.Production + s.Consumption
+}
+
+// buildGraph assembles the multi-layer resource graph for a year: region and
+// resource nodes, region→region flow edges and region→resource→region
+// production/consumption edges, plus resource-type nodes when requested.
+// Region values are in the resource's unit for a single resource; across
+// resources, where units differ, they are the sum of the region's shares of
+// each resource's global total.
+func buildGraph(opts graphOptions) GraphData {
+	colors := resourceColors()
+	selected := make([]Resource, 0, len(resources))
+	for _, r := range resources {
+		if opts.ResourceID == "" || r.ID == opts.ResourceID {
+			selected = append(selected, r)
+		}
+	}
+	single := opts.ResourceID != ""
+
+	nodes := map[string]*GraphNode{}
+	regionNet := map[string]float64{}
+	region := func(id, name string) *GraphNode {
+		key := "region:" + id
+		n, ok := nodes[key]
+		if !ok {
+			n = &GraphNode{ID: key, Label: strings.ToUpper(id), Type: nodeRegion}
+			nodes[key] = n
+		}
+		if name != "" {
+			n.Label = name
+		}
+		return n
+	}
+	edges := make([]GraphEdge, 0)
+	observed := settledFlows()
+
+	for _, r := range selected {
+		rid := "resource:" + r.ID
+		stats := make([]RegionStats, 0)
+		total := 0.0
+		for _, s := range resourceStats[r.ID] {
+			if s.Year == opts.Year {
+				stats = append(stats, s)
+				total += statMeasure(s, opts.SizeBy)
+			}
+		}
+		hasFlows := false
+		for _, f := range observed {
+			hasFlows = hasFlows || (f.ResourceID == r.ID && f.Year == opts.Year)
+		}
+		if len(stats) == 0 && !hasFlows && !single {
+			continue
+		}
+		nodes[rid] = &GraphNode{ID: rid, Label: r.Name, Type: nodeResource, ResourceType: string(r.Type), Value: total, Color: colors[r.ID]}
+
+		for _, s := range stats {
+			n := region(s.RegionID, s.RegionName)
+			m, net := statMeasure(s, opts.SizeBy), s.Export-s.Import
+			if !single {
+				if total > 0 {
+					m /= total
+				}
+				if s.Production+s.Consumption > 0 {
+					net /= s.Production + s.Consumption
+				}
+			}
+			n.Value += m
+			regionNet[n.ID] += net
+			if s.Production > 0 {
+				edges = append(edges, GraphEdge{ID: fmt.Sprintf("produces:%s:%s", s.RegionID, r.ID), Source: n.ID, Target: rid, Type: edgeProduces, ResourceID: r.ID, Weight: s.Production, Color: colors[r.ID]})
+			}
+			if s.Consumption > 0 {
+				edges = append(edges, GraphEdge{ID: fmt.Sprintf("consumes:%s:%s", r.ID, s.RegionID), Source: rid, Target: n.ID, Type: edgeConsumes, ResourceID: r.ID, Weight: s.Consumption, Color: colors[r.ID]})
+			}
+		}
+		for _, f := range observed {
+			if f.ResourceID != r.ID || f.Year != opts.Year {
+				continue
+			}
+			weight := f.Volume
+			if opts.WeightBy == "value" {
+				weight = f.Value
+			}
+			src, dst := region(f.SourceRegion, ""), region(f.TargetRegion, "")
+			edges = append(edges, GraphEdge{ID: "flow:" + f.ID, Source: src.ID, Target: dst.ID, Type: edgeFlow, ResourceID: r.ID, Weight: weight, Color: colors[r.ID]})
+		}
+
+		if opts.IncludeTypes {
+			tid := "type:" + string(r.Type)
+			if _, ok := nodes[tid]; !ok {
+				color, ok := typeColors[r.Type]
+				if !ok {
+					color = colorNeutral
+				}
+				nodes[tid] = &GraphNode{ID: tid, Label: strings.ToUpper(string(r.Type[:1])) + string(r.Type[1:]), Type: nodeResourceType, ResourceType: string(r.Type), Color: color}
+			}
+			nodes[tid].Value++
+			edges = append(edges, GraphEdge{ID: "member_of:" + r.ID, Source: rid, Target: tid, Type: edgeMemberOf, ResourceID: r.ID, Weight: 1, Color: nodes[tid].Color})
+		}
+	}
+
+	largest := map[string]float64{}
+	for _, n := range nodes {
+		largest[n.Type] = math.Max(largest[n.Type], n.Value)
+	}
+	out := make([]GraphNode, 0, len(nodes))
+	for _, n := range nodes {
+		switch n.Type {
+		case nodeRegion:
+			n.Size = scaleSize(n.Value, largest[n.Type], 6, 24)
+			switch net := regionNet[n.ID]; {
+			case net > 0:
+				n.Color = colorExporter
+			case net < 0:
+				n.Color = colorImporter
+			default:
+				n.Color = colorNeutral
+			}
+		case nodeResource:
+			n.Size = scaleSize(n.Value, largest[n.Type], 8, 28)
+		default:
+			n.Size = scaleSize(n.Value, largest[n.Type], 16, 32)
+		}
+		out = append(out, *n)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	sort.SliceStable(edges, func(i, j int) bool { return edges[i].ID < edges[j].ID })
+	return GraphData{Nodes: out, Edges: edges}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// graphFormats maps the get_graph format argument to its media type. "json"
+// is the native GraphData shape and is returned unwrapped.
+var graphFormats = map[string]string{
+	"json":      "application/json",
+	"graphml":   "application/graphml+xml",
+	"gexf":      "application/gexf+xml",
+	"dot":       "text/vnd.graphviz",
+	"cytoscape": "application/json",
+}
+
+// graphAttr describes one node or edge attribute carried into the
+// attribute-typed formats (GraphML keys, GEXF attributes).
+type graphAttr struct {
+	ID   string
+	Type string
+}
+
+var nodeAttrs = []graphAttr{
+	{"label", "string"}, {"type", "string"}, {"resourceType", "string"}, {"value", "double"},
+	{"x", "double"}, {"y", "double"}, {"z", "double"},
+	{"color", "string"}, {"size", "double"},
+}
+
+var edgeAttrs = []graphAttr{
+	{"type", "string"}, {"resourceId", "string"}, {"weight", "double"}, {"color", "string"},
+}
+
+// edgeID falls back to the edge's position for edges built without an ID.
+func edgeID(e GraphEdge, i int) string {
+	if e.ID != "" {
+		return e.ID
+	}
+	return "e" + strconv.Itoa(i)
+}
+
+func nodeValues(n GraphNode) map[string]string {
+	return map[string]string{
+		"label": n.Label, "type": n.Type, "resourceType": n.ResourceType, "value": formatFloat(n.Value),
+		"x": formatFloat(n.X), "y": formatFloat(n.Y), "z": formatFloat(n.Z),
+		"color": n.Color, "size": formatFloat(n.Size),
+	}
+}
+
+func edgeValues(e GraphEdge) map[string]string {
+	return map[string]string{"type": e.Type, "resourceId": e.ResourceID, "weight": formatFloat(e.Weight), "color": e.Color}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// exportGraph serializes g in the requested format. Text formats are
+// returned as content; Cytoscape.js elements as a JSON document.
+func exportGraph(g GraphData, format, title string) (any, error) {
+	if format == "" {
+		format = "json"
+	}
+	mediaType, ok := graphFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format: %s (expected json, graphml, gexf, dot or cytoscape)", format)
+	}
+	out := map[string]any{"format": format, "media_type": mediaType, "nodes": len(g.Nodes), "edges": len(g.Edges)}
+	switch format {
+	case "json":
+		return g, nil
+	case "graphml":
+		out["content"] = graphML(g, title)
+	case "gexf":
+		out["content"] = gexf(g, title)
+	case "dot":
+		out["content"] = graphDOT(g, title)
+	case "cytoscape":
+		out["document"] = cytoscapeJSON(g)
+	}
+	return out, nil
+}
+
+func graphML(g GraphData, title string) string {
+	w := &xmlWriter{}
+	w.line(`<?xml version="1.0" encoding="UTF-8"?>`)
+	w.open("graphml", "xmlns", "http://graphml.graphdrawing.org/xmlns",
+		"xmlns:xsi", "http://www.w3.org/2001/XMLSchema-instance",
+		"xsi:schemaLocation", "http://graphml.graphdrawing.org/xmlns http://graphml.graphdrawing.org/xmlns/1.0/graphml.xsd")
+	for _, a := range nodeAttrs {
+		w.empty("key", "id", "n_"+a.ID, "for", "node", "attr.name", a.ID, "attr.type", a.Type)
+	}
+	for _, a := range edgeAttrs {
+		w.empty("key", "id", "e_"+a.ID, "for", "edge", "attr.name", a.ID, "attr.type", a.Type)
+	}
+	w.open("graph", "id", title, "edgedefault", "directed")
+	for _, n := range g.Nodes {
+		vals := nodeValues(n)
+		w.open("node", "id", n.ID)
+		for _, a := range nodeAttrs {
+			w.text("data", vals[a.ID], "key", "n_"+a.ID)
+		}
+		w.close("node")
+	}
+	for i, e := range g.Edges {
+		vals := edgeValues(e)
+		w.open("edge", "id", edgeID(e, i), "source", e.Source, "target", e.Target)
+		for _, a := range edgeAttrs {
+			w.text("data", vals[a.ID], "key", "e_"+a.ID)
+		}
+		w.close("edge")
+	}
+	w.close("graph")
+	w.close("graphml")
+	return w.b.String()
+}
+
+// gexf writes GEXF 1.3. Label, position, size and color go to the viz
+// namespace that Gephi reads; the remaining fields become attributes.
+func gexf(g GraphData, title string) string {
+	w := &xmlWriter{}
+	w.line(`<?xml version="1.0" encoding="UTF-8"?>`)
+	w.open("gexf", "xmlns", "http://gexf.net/1.3", "xmlns:viz", "http://gexf.net/1.3/viz", "version", "1.3")
+	w.open("meta")
+	w.text("creator", serviceName)
+	w.text("description", title)
+	w.close("meta")
+	w.open("graph", "defaultedgetype", "directed", "mode", "static")
+	w.open("attributes", "class", "node")
+	w.empty("attribute", "id", "type", "title", "type", "type", "string")
+	w.empty("attribute", "id", "resourceType", "title", "resourceType", "type", "string")
+	w.empty("attribute", "id", "value", "title", "value", "type", "double")
+	w.empty("attribute", "id", "color", "title", "color", "type", "string")
+	w.close("attributes")
+	w.open("attributes", "class", "edge")
+	w.empty("attribute", "id", "type", "title", "type", "type", "string")
+	w.empty("attribute", "id", "resourceId", "title", "resourceId", "type", "string")
+	w.empty("attribute", "id", "color", "title", "color", "type", "string")
+	w.close("attributes")
+	w.open("nodes")
+	for _, n := range g.Nodes {
+		w.open("node", "id", n.ID, "label", n.Label)
+		w.open("attvalues")
+		w.empty("attvalue", "for", "type", "value", n.Type)
+		w.empty("attvalue", "for", "resourceType", "value", n.ResourceType)
+		w.empty("attvalue", "for", "value", "value", formatFloat(n.Value))
+		w.empty("attvalue", "for", "color", "value", n.Color)
+		w.close("attvalues")
+		if r, gr, b, ok := hexRGB(n.Color); ok {
+			w.empty("viz:color", "r", strconv.Itoa(r), "g", strconv.Itoa(gr), "b", strconv.Itoa(b))
+		}
+		w.empty("viz:position", "x", formatFloat(n.X), "y", formatFloat(n.Y), "z", formatFloat(n.Z))
+		w.empty("viz:size", "value", formatFloat(n.Size))
+		w.close("node")
+	}
+	w.close("nodes")
+	w.open("edges")
+	for i, e := range g.Edges {
+		w.open("edge", "id", edgeID(e, i), "source", e.Source, "target", e.Target, "weight", formatFloat(e.Weight))
+		w.open("attvalues")
+		w.empty("attvalue", "for", "type", "value", e.Type)
+		w.empty("attvalue", "for", "resourceId", "value", e.ResourceID)
+		w.empty("attvalue", "for", "color", "value", e.Color)
+		w.close("attvalues")
+		if r, gr, b, ok := hexRGB(e.Color); ok {
+			w.empty("viz:color", "r", strconv.Itoa(r), "g", strconv.Itoa(gr), "b", strconv.Itoa(b))
+		}
+		w.close("edge")
+	}
+	w.close("edges")
+	w.close("graph")
+	w.close("gexf")
+	return w.b.String()
+}
+
+// graphDOT writes a Graphviz digraph. Positions use pos with "!" so neato
+// and fdp keep the server layout; z is kept as a plain attribute.
+func graphDOT(g GraphData, title string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotID(title))
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&b, "  %s [label=%s, type=%s, resourceType=%s, value=%s, pos=%s, z=%s, color=%s, size=%s];\n",
+			dotID(n.ID), dotID(n.Label), dotID(n.Type), dotID(n.ResourceType), formatFloat(n.Value),
+			dotID(formatFloat(n.X)+","+formatFloat(n.Y)+"!"), formatFloat(n.Z),
+			dotID(n.Color), formatFloat(n.Size))
+	}
+	for i, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -> %s [id=%s, type=%s, resourceId=%s, weight=%s, color=%s];\n",
+			dotID(e.Source), dotID(e.Target), dotID(edgeID(e, i)), dotID(e.Type), dotID(e.ResourceID), formatFloat(e.Weight), dotID(e.Color))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotID quotes a DOT identifier.
+func dotID(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// cytoscapeJSON returns the elements form accepted by cy.add / cy.json.
+func cytoscapeJSON(g GraphData) map[string]any {
+	nodes := make([]map[string]any, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		nodes = append(nodes, map[string]any{
+			"data": map[string]any{
+				"id": n.ID, "label": n.Label, "type": n.Type, "resourceType": n.ResourceType, "value": n.Value,
+				"color": n.Color, "size": n.Size, "z": n.Z,
+			},
+			"position": map[string]float64{"x": n.X, "y": n.Y},
+		})
+	}
+	edges := make([]map[string]any, 0, len(g.Edges))
+	for i, e := range g.Edges {
+		edges = append(edges, map[string]any{
+			"data": map[string]any{
+				"id": edgeID(e, i), "source": e.Source, "target": e.Target,
+				"type": e.Type, "resourceId": e.ResourceID, "weight": e.Weight, "color": e.Color,
+			},
+		})
+	}
+	return map[string]any{"elements": map[string]any{"nodes": nodes, "edges": edges}}
+}
+
+// hexRGB parses #rrggbb colors.
+func hexRGB(s string) (r, g, b int, ok bool) {
+	if len(s) != 7 || s[0] != '#' {
+		return 0, 0, 0, false
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff), true
+}
+
+// xmlWriter is a minimal indenting writer for the XML graph formats.
+type xmlWriter struct {
+	b     strings.Builder
+	depth int
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func (w *xmlWriter) open(tag string, attrs ...string) {
+	w.line("<" + tag + xmlAttrs(attrs) + ">")
+	w.depth++
+}
+
+func (w *xmlWriter) close(tag string) {
+	w.depth--
+	w.line("</" + tag + ">")
+}
+
+func (w *xmlWriter) empty(tag string, attrs ...string) {
+	w.line("<" + tag + xmlAttrs(attrs) + "/>")
+}
+
+func (w *xmlWriter) text(tag, text string, attrs ...string) {
+	w.line("<" + tag + xmlAttrs(attrs) + ">" + xmlEscape(text) + "</" + tag + ">")
+}
+
+func (w *xmlWriter) line(s string) {
+	w.b.WriteString(strings.Repeat("  ", w.depth))
+	w.b.WriteString(s)
+	w.b.WriteByte('\n')
+}
+
+// xmlAttrs renders name/value pairs.
+func xmlAttrs(kv []string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&b, ` %s="%s"`, kv[i], xmlEscape(kv[i+1]))
+	}
+	return b.String()
+}
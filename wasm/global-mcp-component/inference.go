@@ -0,0 +1,380 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// inferenceMethods are the seeds of the proportional fitting: "ras" starts
+// from a uniform matrix, "gravity" from export × import / distance^decay.
+var inferenceMethods = []string{"gravity", "ras"}
+
+const (
+	maxFittingIterations = 500
+	fittingTolerance     = 1e-6
+	// minGravityDistance keeps neighbouring regions from dominating the seed.
+	minGravityDistance = 500.0
+	earthRadiusKm      = 6371.0
+	// balanceTolerance is the share of max(production, consumption) by which
+	// net trade may differ from production − consumption (stock changes).
+	balanceTolerance = 0.05
+)
+
+// inferenceBaseConfidence reflects how much structure each seed brings;
+// flows to or from the rest of the world get half, as the partner is unknown.
+var inferenceBaseConfidence = map[string]float64{"gravity": 0.6, "ras": 0.4}
+
+var (
+	inferredMu     sync.Mutex
+	inferredByYear = map[string][]ResourceFlow{}
+	// replacesObserved marks stored sets inferred with keep_observed=false:
+	// they cover the full totals and stand in for the observed flows.
+	replacesObserved = map[string]bool{}
+)
+
+func inferredKey(resourceID string, year int) string {
+	return fmt.Sprintf("%s|%d", resourceID, year)
+}
+
+// storedInferredFlows returns stored inferred flows, optionally filtered by
+// resource and year, in a stable order.
+func storedInferredFlows(resourceID string, year int) []ResourceFlow {
+	inferredMu.Lock()
+	defer inferredMu.Unlock()
+	out := make([]ResourceFlow, 0)
+	for _, key := range sortedKeys(inferredByYear) {
+		for _, f := range inferredByYear[key] {
+			if (resourceID == "" || f.ResourceID == resourceID) && (year == 0 || f.Year == year) {
+				out = append(out, f)
+			}
+		}
+	}
+	return out
+}
+
+// inferredReplacesObserved reports whether the stored inferred flows of a
+// resource and year were fitted without the observed flows.
+func inferredReplacesObserved(resourceID string, year int) bool {
+	inferredMu.Lock()
+	defer inferredMu.Unlock()
+	return replacesObserved[inferredKey(resourceID, year)]
+}
+
+// inferFlows estimates the bilateral flows of one resource and year whose
+// row and column totals match the regions' reported exports and imports,
+// or their production − consumption balance where no trade is reported.
+// Observed flows are held fixed by default; only the remainder is inferred.
+func inferFlows(args map[string]any) (any, error) {
+	resourceID, _ := args["resource_id"].(string)
+	if resourceByID(resourceID) == nil {
+		return nil, fmt.Errorf("resource not found: %s", resourceID)
+	}
+	year := toInt(args["year"])
+	if year == 0 {
+		year = latestStatsYear(resourceID)
+	}
+	method, _ := args["method"].(string)
+	if method == "" {
+		method = "gravity"
+	}
+	if !containsString(inferenceMethods, method) {
+		return nil, fmt.Errorf("method must be one of %v", inferenceMethods)
+	}
+	decay := 1.0
+	if v, ok := args["distance_decay"].(float64); ok {
+		if v < 0 {
+			return nil, fmt.Errorf("distance_decay must not be negative")
+		}
+		decay = v
+	}
+	balance, _ := args["balance"].(string)
+	if balance == "" {
+		balance = "rest_of_world"
+	}
+	if balance != "rest_of_world" && balance != "scale" {
+		return nil, fmt.Errorf("balance must be rest_of_world or scale")
+	}
+	keepObserved := true
+	if v, ok := args["keep_observed"].(bool); ok {
+		keepObserved = v
+	}
+	store := true
+	if v, ok := args["store"].(bool); ok {
+		store = v
+	}
+
+	stats := make([]RegionStats, 0)
+	for _, s := range resourceStats[resourceID] {
+		if s.Year == year {
+			stats = append(stats, s)
+		}
+	}
+	if len(stats) == 0 {
+		if year == 0 {
+			return nil, fmt.Errorf("no stats for %s", resourceID)
+		}
+		return nil, fmt.Errorf("no stats for %s in %d", resourceID, year)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].RegionID < stats[j].RegionID })
+
+	observedOut, observedIn := map[string]float64{}, map[string]float64{}
+	unitValue, volumeSum, valueSum := 0.0, 0.0, 0.0
+	observed := 0
+	for _, f := range settledFlows() {
+		if f.ResourceID != resourceID || f.Year != year || f.SourceRegion == f.TargetRegion {
+			continue
+		}
+		volumeSum += f.Volume
+		valueSum += f.Value
+		if keepObserved {
+			observedOut[f.SourceRegion] += f.Volume
+			observedIn[f.TargetRegion] += f.Volume
+			observed++
+		}
+	}
+	if volumeSum > 0 {
+		unitValue = valueSum / volumeSum
+	}
+
+	exporters, importers := make([]string, 0), make([]string, 0)
+	rowTarget, colTarget := make([]float64, 0), make([]float64, 0)
+	sumRows, sumCols := 0.0, 0.0
+	filled, balanceGaps := make([]string, 0), make([]map[string]any, 0)
+	for _, s := range stats {
+		exporters = append(exporters, s.RegionID)
+		importers = append(importers, s.RegionID)
+		export, imp, fromBalance := tradeTotals(s)
+		if fromBalance {
+			filled = append(filled, s.RegionID)
+		} else if gap := (export - imp) - (s.Production - s.Consumption); math.Abs(gap) > balanceTolerance*math.Max(s.Production, s.Consumption) {
+			balanceGaps = append(balanceGaps, map[string]any{
+				"regionId": s.RegionID, "netTrade": round6(export - imp), "netSupply": round6(s.Production - s.Consumption), "gap": round6(gap),
+			})
+		}
+		e := math.Max(0, export-observedOut[s.RegionID])
+		m := math.Max(0, imp-observedIn[s.RegionID])
+		rowTarget = append(rowTarget, e)
+		colTarget = append(colTarget, m)
+		sumRows += e
+		sumCols += m
+	}
+	restVolume := 0.0
+	switch {
+	case math.Abs(sumRows-sumCols) <= fittingTolerance:
+	case balance == "scale" && sumRows > 0 && sumCols > 0:
+		// Scale the larger side down so both sum to the smaller total.
+		total := math.Min(sumRows, sumCols)
+		for i := range rowTarget {
+			rowTarget[i] *= total / sumRows
+		}
+		for j := range colTarget {
+			colTarget[j] *= total / sumCols
+		}
+	case sumRows < sumCols:
+		exporters = append(exporters, restOfWorld)
+		rowTarget = append(rowTarget, sumCols-sumRows)
+		restVolume = sumCols - sumRows
+	default:
+		importers = append(importers, restOfWorld)
+		colTarget = append(colTarget, sumRows-sumCols)
+		restVolume = sumRows - sumCols
+	}
+
+	seed := inferenceSeed(method, decay, exporters, importers, rowTarget, colTarget)
+	x, iterations, fitErr := fitMarginals(seed, rowTarget, colTarget)
+	converged := fitErr < fittingTolerance
+	fit := clamp01(1 - fitErr)
+
+	total := 0.0
+	for _, row := range x {
+		for _, v := range row {
+			total += v
+		}
+	}
+	inferred := make([]ResourceFlow, 0)
+	for i, src := range exporters {
+		for j, dst := range importers {
+			if x[i][j] <= total*1e-9 || x[i][j] <= 0 {
+				continue
+			}
+			confidence := inferenceBaseConfidence[method] * fit
+			if src == restOfWorld || dst == restOfWorld {
+				confidence /= 2
+			}
+			inferred = append(inferred, ResourceFlow{
+				ID:         fmt.Sprintf("inf-%s-%s-%s-%d", resourceID, src, dst, year),
+				ResourceID: resourceID, SourceRegion: src, TargetRegion: dst, Year: year,
+				Volume: round6(x[i][j]), Value: round6(x[i][j] * unitValue),
+				Inferred: true, Method: method, Confidence: round3(confidence),
+			})
+		}
+	}
+	if store {
+		inferredMu.Lock()
+		inferredByYear[inferredKey(resourceID, year)] = inferred
+		replacesObserved[inferredKey(resourceID, year)] = !keepObserved
+		inferredMu.Unlock()
+	}
+
+	marginals := map[string]any{"exports": map[string]float64{}, "imports": map[string]float64{}}
+	for i, id := range exporters {
+		marginals["exports"].(map[string]float64)[id] = round6(rowTarget[i])
+	}
+	for j, id := range importers {
+		marginals["imports"].(map[string]float64)[id] = round6(colTarget[j])
+	}
+	return map[string]any{
+		"resource_id":        resourceID,
+		"year":               year,
+		"method":             method,
+		"distance_decay":     decay,
+		"balance":            balance,
+		"observed_held":      observed,
+		"marginals":          marginals,
+		"filled_from_supply": filled,
+		"balance_gaps":       balanceGaps,
+		"rest_of_world":      round6(restVolume),
+		"iterations":         iterations,
+		"converged":          converged,
+		"max_marginal_error": round6(fitErr),
+		"unit_value":         round6(unitValue),
+		"confidence_formula": "base × (1 − max marginal error), base gravity 0.6 / ras 0.4, halved for rest-of-world flows",
+		"flows":              inferred,
+		"count":              len(inferred),
+		"stored":             store,
+		"replaces_observed":  store && !keepObserved,
+	}, nil
+}
+
+// tradeTotals returns the export and import totals of a region. A region that
+// reports no trade but produces more or less than it consumes trades the
+// difference: a surplus is exported, a deficit imported.
+func tradeTotals(s RegionStats) (float64, float64, bool) {
+	if s.Export > 0 || s.Import > 0 {
+		return s.Export, s.Import, false
+	}
+	net := s.Production - s.Consumption
+	if net == 0 {
+		return 0, 0, false
+	}
+	return math.Max(0, net), math.Max(0, -net), true
+}
+
+// inferenceSeed builds the starting matrix. Self-trade and rows or columns
+// with nothing left to allocate are zero. Pairs with observed flows stay
+// open: the records may cover only part of the trade between them.
+func inferenceSeed(method string, decay float64, exporters, importers []string, rowTarget, colTarget []float64) [][]float64 {
+	// The rest of the world sits at the mean distance between known regions.
+	meanDistance, pairs := 0.0, 0
+	for _, a := range exporters {
+		for _, b := range importers {
+			if d, ok := regionDistance(a, b); ok && a != b {
+				meanDistance += d
+				pairs++
+			}
+		}
+	}
+	if pairs > 0 {
+		meanDistance /= float64(pairs)
+	} else {
+		meanDistance = minGravityDistance
+	}
+	seed := make([][]float64, len(exporters))
+	for i, src := range exporters {
+		seed[i] = make([]float64, len(importers))
+		for j, dst := range importers {
+			if src == dst || rowTarget[i] <= 0 || colTarget[j] <= 0 {
+				continue
+			}
+			if method == "ras" {
+				seed[i][j] = 1
+				continue
+			}
+			d, ok := regionDistance(src, dst)
+			if !ok {
+				d = meanDistance
+			}
+			seed[i][j] = rowTarget[i] * colTarget[j] / math.Pow(math.Max(d, minGravityDistance), decay)
+		}
+	}
+	return seed
+}
+
+// fitMarginals is iterative proportional fitting (RAS): rows and columns are
+// rescaled in turn until both sets of totals match. It returns the fitted
+// matrix, the iterations used and the largest relative marginal error.
+func fitMarginals(seed [][]float64, rowTarget, colTarget []float64) ([][]float64, int, float64) {
+	x := make([][]float64, len(seed))
+	for i := range seed {
+		x[i] = append([]float64(nil), seed[i]...)
+	}
+	fitErr := math.Inf(1)
+	it := 0
+	for it < maxFittingIterations && fitErr >= fittingTolerance {
+		it++
+		for i := range x {
+			sum := 0.0
+			for _, v := range x[i] {
+				sum += v
+			}
+			if sum > 0 {
+				for j := range x[i] {
+					x[i][j] *= rowTarget[i] / sum
+				}
+			}
+		}
+		for j := range colTarget {
+			sum := 0.0
+			for i := range x {
+				sum += x[i][j]
+			}
+			if sum > 0 {
+				for i := range x {
+					x[i][j] *= colTarget[j] / sum
+				}
+			}
+		}
+		fitErr = marginalError(x, rowTarget, colTarget)
+	}
+	return x, it, fitErr
+}
+
+func marginalError(x [][]float64, rowTarget, colTarget []float64) float64 {
+	worst := 0.0
+	relative := func(got, want float64) float64 {
+		if want <= 0 {
+			return 0
+		}
+		return math.Abs(got-want) / want
+	}
+	for i := range x {
+		sum := 0.0
+		for _, v := range x[i] {
+			sum += v
+		}
+		worst = math.Max(worst, relative(sum, rowTarget[i]))
+	}
+	for j := range colTarget {
+		sum := 0.0
+		for i := range x {
+			sum += x[i][j]
+		}
+		worst = math.Max(worst, relative(sum, colTarget[j]))
+	}
+	return worst
+}
+
+// regionDistance is the great-circle distance in km between two regions.
+func regionDistance(a, b string) (float64, bool) {
+	lat1, lng1, ok1 := regionLocation(a)
+	lat2, lng2, ok2 := regionLocation(b)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	rad := math.Pi / 180
+	p1, p2 := lat1*rad, lat2*rad
+	h := math.Pow(math.Sin((p2-p1)/2), 2) + math.Cos(p1)*math.Cos(p2)*math.Pow(math.Sin((lng2-lng1)*rad/2), 2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h)), true
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Layouts selectable with the layout argument of global.get_graph.
+const (
+	layoutForce = "force"
+	layoutGeo   = "geo"
+	layoutNone  = "none"
+)
+
+const (
+	layoutRadius        = 100.0
+	defaultLayoutSeed   = 1
+	defaultLayoutIters  = 300
+	maxLayoutIters      = 2000
+	layoutCacheCapacity = 64
+	resourceOrbitFactor = 0.55
+	typeOrbitFactor     = 0.3
+)
+
+type layoutOptions struct {
+	Name       string
+	Seed       int
+	Iterations int
+}
+
+// LayoutInfo describes how node positions were computed. Version identifies
+// the graph structure and layout parameters the positions belong to.
+type LayoutInfo struct {
+	Name       string `json:"name"`
+	Seed       int    `json:"seed,omitempty"`
+	Iterations int    `json:"iterations,omitempty"`
+	Version    string `json:"version"`
+	Cached     bool   `json:"cached"`
+}
+
+func layoutOptionsFromArgs(args map[string]any) (layoutOptions, error) {
+	opts := layoutOptions{Seed: defaultLayoutSeed, Iterations: defaultLayoutIters}
+	opts.Name, _ = args["layout"].(string)
+	if opts.Name == "" {
+		opts.Name = layoutForce
+	}
+	if opts.Name != layoutForce && opts.Name != layoutGeo && opts.Name != layoutNone {
+		return opts, fmt.Errorf("layout must be %s, %s or %s", layoutForce, layoutGeo, layoutNone)
+	}
+	if _, ok := args["seed"]; ok {
+		opts.Seed = toInt(args["seed"])
+	}
+	if n := toInt(args["iterations"]); n > 0 {
+		opts.Iterations = n
+	}
+	if opts.Iterations > maxLayoutIters {
+		opts.Iterations = maxLayoutIters
+	}
+	return opts, nil
+}
+
+var (
+	layoutMu    sync.Mutex
+	layoutCache = map[string]map[string][3]float64{}
+	layoutOrder []string
+)
+
+// graphVersion hashes everything a layout depends on: node IDs, types and
+// sizes, weighted edges, and the layout parameters.
+func graphVersion(g GraphData, opts layoutOptions) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%d|%d\n", opts.Name, opts.Seed, opts.Iterations)
+	for _, n := range g.Nodes {
+		fmt.Fprintf(h, "n|%s|%s|%g\n", n.ID, n.Type, n.Size)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(h, "e|%s|%s|%s|%g\n", e.ID, e.Source, e.Target, e.Weight)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// applyLayout sets X/Y/Z on g's nodes, reusing cached positions when the
+// same graph was laid out with the same parameters before.
+func applyLayout(g *GraphData, opts layoutOptions) *LayoutInfo {
+	if opts.Name == layoutNone {
+		return nil
+	}
+	info := &LayoutInfo{Name: opts.Name, Version: graphVersion(*g, opts)}
+	if opts.Name == layoutForce {
+		info.Seed, info.Iterations = opts.Seed, opts.Iterations
+	}
+
+	layoutMu.Lock()
+	pos, ok := layoutCache[info.Version]
+	layoutMu.Unlock()
+	info.Cached = ok
+	if !ok {
+		if opts.Name == layoutGeo {
+			pos = geoLayout(*g)
+		} else {
+			pos = forceLayout(*g, opts.Seed, opts.Iterations)
+		}
+		layoutMu.Lock()
+		if _, exists := layoutCache[info.Version]; !exists {
+			layoutCache[info.Version] = pos
+			layoutOrder = append(layoutOrder, info.Version)
+			if len(layoutOrder) > layoutCacheCapacity {
+				delete(layoutCache, layoutOrder[0])
+				layoutOrder = layoutOrder[1:]
+			}
+		}
+		layoutMu.Unlock()
+	}
+	for i := range g.Nodes {
+		p := pos[g.Nodes[i].ID]
+		g.Nodes[i].X, g.Nodes[i].Y, g.Nodes[i].Z = p[0], p[1], p[2]
+	}
+	return info
+}
+
+// splitmix64 is a small deterministic PRNG, so layouts do not depend on the
+// runtime's math/rand implementation.
+type splitmix64 uint64
+
+func (s *splitmix64) next() float64 {
+	*s += 0x9e3779b97f4a7c15
+	z := uint64(*s)
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+	return float64(z>>11) / float64(1<<53)
+}
+
+// nodeRand seeds a generator from the layout seed and the node ID, so a
+// node's starting point does not move when other nodes are added.
+func nodeRand(seed int, id string) splitmix64 {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	return splitmix64(uint64(seed)*0x9e3779b97f4a7c15 ^ h.Sum64())
+}
+
+// forceLayout runs a 3D Fruchterman–Reingold layout with linear cooling.
+// Parallel edges are merged and attraction grows with relative weight; a weak
+// pull towards the origin keeps disconnected components in view.
+func forceLayout(g GraphData, seed, iterations int) map[string][3]float64 {
+	n := len(g.Nodes)
+	pos := make([][3]float64, n)
+	index := make(map[string]int, n)
+	for i, node := range g.Nodes {
+		index[node.ID] = i
+		r := nodeRand(seed, node.ID)
+		for d := 0; d < 3; d++ {
+			pos[i][d] = (r.next()*2 - 1) * layoutRadius
+		}
+	}
+
+	type pair struct{ a, b int }
+	weights := map[pair]float64{}
+	maxWeight := 0.0
+	for _, e := range g.Edges {
+		a, okA := index[e.Source]
+		b, okB := index[e.Target]
+		if !okA || !okB || a == b {
+			continue
+		}
+		if a > b {
+			a, b = b, a
+		}
+		weights[pair{a, b}] += math.Abs(e.Weight)
+		maxWeight = math.Max(maxWeight, weights[pair{a, b}])
+	}
+	springs := make([]pair, 0, len(weights))
+	for p := range weights {
+		springs = append(springs, p)
+	}
+	sort.Slice(springs, func(i, j int) bool {
+		if springs[i].a != springs[j].a {
+			return springs[i].a < springs[j].a
+		}
+		return springs[i].b < springs[j].b
+	})
+
+	k := layoutRadius / math.Cbrt(math.Max(float64(n), 1))
+	disp := make([][3]float64, n)
+	for it := 0; it < iterations; it++ {
+		temp := layoutRadius / 10 * (1 - float64(it)/float64(iterations))
+		for i := range disp {
+			disp[i] = [3]float64{}
+		}
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				d, dist := delta(pos[i], pos[j])
+				f := k * k / dist
+				for c := 0; c < 3; c++ {
+					disp[i][c] += d[c] / dist * f
+					disp[j][c] -= d[c] / dist * f
+				}
+			}
+		}
+		for _, s := range springs {
+			w := 1.0
+			if maxWeight > 0 {
+				w = 0.5 + weights[s]/maxWeight
+			}
+			d, dist := delta(pos[s.a], pos[s.b])
+			f := dist * dist / k * w
+			for c := 0; c < 3; c++ {
+				disp[s.a][c] -= d[c] / dist * f
+				disp[s.b][c] += d[c] / dist * f
+			}
+		}
+		for i := 0; i < n; i++ {
+			for c := 0; c < 3; c++ {
+				disp[i][c] -= pos[i][c] * 0.05
+			}
+			length := math.Sqrt(disp[i][0]*disp[i][0] + disp[i][1]*disp[i][1] + disp[i][2]*disp[i][2])
+			if length == 0 {
+				continue
+			}
+			step := math.Min(length, temp)
+			for c := 0; c < 3; c++ {
+				pos[i][c] += disp[i][c] / length * step
+			}
+		}
+	}
+	return normalizePositions(g, pos)
+}
+
+func delta(a, b [3]float64) ([3]float64, float64) {
+	d := [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+	dist := math.Sqrt(d[0]*d[0] + d[1]*d[1] + d[2]*d[2])
+	return d, math.Max(dist, 0.01)
+}
+
+// normalizePositions centers the layout and scales it to layoutRadius.
+func normalizePositions(g GraphData, pos [][3]float64) map[string][3]float64 {
+	var center [3]float64
+	for _, p := range pos {
+		for c := 0; c < 3; c++ {
+			center[c] += p[c] / float64(len(pos))
+		}
+	}
+	extent := 0.0
+	for i := range pos {
+		for c := 0; c < 3; c++ {
+			pos[i][c] -= center[c]
+		}
+		extent = math.Max(extent, math.Sqrt(pos[i][0]*pos[i][0]+pos[i][1]*pos[i][1]+pos[i][2]*pos[i][2]))
+	}
+	scale := 1.0
+	if extent > 0 {
+		scale = layoutRadius / extent
+	}
+	out := make(map[string][3]float64, len(pos))
+	for i, node := range g.Nodes {
+		out[node.ID] = [3]float64{round3(pos[i][0] * scale), round3(pos[i][1] * scale), round3(pos[i][2] * scale)}
+	}
+	return out
+}
+
+// geoLayout places regions on a sphere of layoutRadius (y up, lng 0 facing +x)
+// and pulls resource and type nodes inside it, towards the weighted centroid
+// of the regions or resources they connect to.
+func geoLayout(g GraphData) map[string][3]float64 {
+	out := map[string][3]float64{}
+	unplaced := 0
+	for _, n := range g.Nodes {
+		if n.Type != nodeRegion {
+			continue
+		}
+		lat, lng, ok := regionLocation(n.ID[len("region:"):])
+		if !ok {
+			// Unknown regions go to the south pole, spaced along a small ring.
+			lat, lng = -85, float64(unplaced*30)
+			unplaced++
+		}
+		out[n.ID] = sphere(lat, lng, layoutRadius)
+	}
+
+	centroid := func(id string, placedType string, radius float64) ([3]float64, bool) {
+		var sum [3]float64
+		total := 0.0
+		for _, e := range g.Edges {
+			other := ""
+			switch id {
+			case e.Source:
+				other = e.Target
+			case e.Target:
+				other = e.Source
+			}
+			p, ok := out[other]
+			if other == "" || !ok || !hasType(g, other, placedType) {
+				continue
+			}
+			w := math.Max(math.Abs(e.Weight), 1e-9)
+			for c := 0; c < 3; c++ {
+				sum[c] += p[c] * w
+			}
+			total += w
+		}
+		length := math.Sqrt(sum[0]*sum[0] + sum[1]*sum[1] + sum[2]*sum[2])
+		if total == 0 || length < 1e-9 {
+			return [3]float64{}, false
+		}
+		return [3]float64{round3(sum[0] / length * radius), round3(sum[1] / length * radius), round3(sum[2] / length * radius)}, true
+	}
+
+	for _, layer := range []struct {
+		node, from string
+		radius     float64
+	}{
+		{nodeResource, nodeRegion, layoutRadius * resourceOrbitFactor},
+		{nodeResourceType, nodeResource, layoutRadius * typeOrbitFactor},
+	} {
+		ring := 0
+		for _, n := range g.Nodes {
+			if n.Type != layer.node {
+				continue
+			}
+			p, ok := centroid(n.ID, layer.from, layer.radius)
+			if !ok {
+				p = sphere(0, float64(ring*45), layer.radius)
+				ring++
+			}
+			out[n.ID] = p
+		}
+	}
+	return out
+}
+
+func hasType(g GraphData, id, nodeType string) bool {
+	for _, n := range g.Nodes {
+		if n.ID == id {
+			return n.Type == nodeType
+		}
+	}
+	return false
+}
+
+func sphere(lat, lng, radius float64) [3]float64 {
+	phi, lambda := lat*math.Pi/180, lng*math.Pi/180
+	return [3]float64{
+		round3(radius * math.Cos(phi) * math.Cos(lambda)),
+		round3(radius * math.Sin(phi)),
+		round3(-radius * math.Cos(phi) * math.Sin(lambda)),
+	}
+}
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
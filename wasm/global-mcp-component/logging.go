@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const serviceName = "global-mcp-component"
+
+// logEvent writes one JSON object per line to stderr, which the wasmCloud host
+// forwards to its log pipeline.
+func logEvent(tc traceContext, level, msg string, fields map[string]any) {
+	entry := map[string]any{
+		"ts":      time.Now().UTC().Format(time.RFC3339Nano),
+		"level":   level,
+		"service": serviceName,
+		"msg":     msg,
+	}
+	if tc.TraceID != "" {
+		entry["trace_id"] = tc.TraceID
+		entry["span_id"] = tc.SpanID
+	}
+	for k, v := range fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		entry[k] = v
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	_, _ = os.Stderr.Write(append(raw, '\n'))
+}
+
+// traceContext carries a W3C traceparent (version 00) through a request and
+// into the outgoing calls it makes.
+type traceContext struct {
+	TraceID string
+	SpanID  string
+	Flags   string
+}
+
+// traceFromRequest continues the caller's trace when a valid traceparent is
+// present and otherwise starts a new sampled one. Either way the returned
+// context has a fresh span ID for this component's work.
+func traceFromRequest(r *http.Request) traceContext {
+	if tc, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+		return tc.child()
+	}
+	return traceContext{TraceID: randomHex(16), SpanID: randomHex(8), Flags: "01"}
+}
+
+func parseTraceparent(h string) (traceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return traceContext{}, false
+	}
+	if !isHex(parts[1], 32) || !isHex(parts[2], 16) || !isHex(parts[3], 2) {
+		return traceContext{}, false
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return traceContext{}, false
+	}
+	return traceContext{TraceID: parts[1], SpanID: parts[2], Flags: parts[3]}, true
+}
+
+// child returns a new span in the same trace, used for each outgoing call.
+func (tc traceContext) child() traceContext {
+	return traceContext{TraceID: tc.TraceID, SpanID: randomHex(8), Flags: tc.Flags}
+}
+
+func (tc traceContext) header() string {
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + tc.Flags
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// fall back to the clock so IDs are still non-zero and mostly unique
+		ts := time.Now().UnixNano()
+		for i := range b {
+			b[i] = byte(ts >> (8 * (i % 8)))
+		}
+		b[0] |= 1
+	}
+	return hex.EncodeToString(b)
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, ch := range s {
+		if (ch < '0' || ch > '9') && (ch < 'a' || ch > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// statusRecorder captures the response status for the request log line.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultMaxLoops = 1000
+	maxLoopLimit    = 10000
+)
+
+// feedbackLoop is one elementary cycle of a system model. Its polarity is the
+// product of the edge polarities: an even number of "-" edges reinforces, an
+// odd number balances. IDs follow the causal-loop convention R1, B1, ...
+type feedbackLoop struct {
+	ID            string   `json:"id"`
+	Polarity      string   `json:"polarity"`
+	Nodes         []string `json:"nodes"`
+	Length        int      `json:"length"`
+	NegativeEdges int      `json:"negativeEdges"`
+	HasDelay      bool     `json:"hasDelay"`
+	DelayEdges    []string `json:"delayEdges"`
+	HasStock      bool     `json:"hasStock"`
+}
+
+type structuralIssue struct {
+	Kind     string `json:"kind"`
+	Severity string `json:"severity"`
+	Node     string `json:"node,omitempty"`
+	Edge     string `json:"edge,omitempty"`
+	Loop     string `json:"loop,omitempty"`
+	Message  string `json:"message"`
+}
+
+// analyzeSystem enumerates the feedback loops of a system model with
+// Johnson's algorithm, classifies their polarity and reports structural
+// issues in the model.
+func analyzeSystem(args map[string]any) (any, error) {
+	systemID, _ := args["system_id"].(string)
+	var sys *SystemModel
+	for i := range systems {
+		if systems[i].ID == systemID {
+			sys = &systems[i]
+		}
+	}
+	if sys == nil {
+		return nil, fmt.Errorf("system not found: %s", systemID)
+	}
+	limit := defaultMaxLoops
+	if v := toInt(args["max_loops"]); v > 0 {
+		limit = v
+	}
+	if limit > maxLoopLimit {
+		return nil, fmt.Errorf("max_loops must be at most %d", maxLoopLimit)
+	}
+
+	issues := make([]structuralIssue, 0)
+	index := map[string]int{}
+	for i, n := range sys.Nodes {
+		if _, ok := index[n.ID]; ok {
+			issues = append(issues, structuralIssue{Kind: "duplicate_node", Severity: "error", Node: n.ID, Message: fmt.Sprintf("node %s is defined more than once", n.ID)})
+			continue
+		}
+		index[n.ID] = i
+		if n.Level != "stock" && n.Level != "flow" && n.Level != "auxiliary" {
+			issues = append(issues, structuralIssue{Kind: "unknown_level", Severity: "warning", Node: n.ID, Message: fmt.Sprintf("node %s has level %q, expected stock, flow or auxiliary", n.ID, n.Level)})
+		}
+	}
+
+	// Keep the first edge of each pair; later duplicates are reported.
+	adj := make([][]int, len(sys.Nodes))
+	edges := map[[2]int]SystemEdge{}
+	inputs, outputs := map[string]int{}, map[string]int{}
+	for _, e := range sys.Edges {
+		key := delayKey(e.Source, e.Target)
+		s, okS := index[e.Source]
+		t, okT := index[e.Target]
+		if !okS || !okT {
+			missing := e.Source
+			if okS {
+				missing = e.Target
+			}
+			issues = append(issues, structuralIssue{Kind: "missing_node", Severity: "error", Edge: key, Message: fmt.Sprintf("edge %s references missing node %s", key, missing)})
+			continue
+		}
+		if e.Polarity != "+" && e.Polarity != "-" {
+			issues = append(issues, structuralIssue{Kind: "unknown_polarity", Severity: "warning", Edge: key, Message: fmt.Sprintf("edge %s has polarity %q, expected + or -", key, e.Polarity)})
+		}
+		if _, ok := edges[[2]int{s, t}]; ok {
+			issues = append(issues, structuralIssue{Kind: "duplicate_edge", Severity: "warning", Edge: key, Message: fmt.Sprintf("edge %s is defined more than once; the first is used", key)})
+			continue
+		}
+		edges[[2]int{s, t}] = e
+		adj[s] = append(adj[s], t)
+		inputs[e.Target]++
+		outputs[e.Source]++
+	}
+	for _, n := range sys.Nodes {
+		switch {
+		case inputs[n.ID] == 0 && outputs[n.ID] == 0:
+			issues = append(issues, structuralIssue{Kind: "isolated_node", Severity: "warning", Node: n.ID, Message: fmt.Sprintf("node %s has no edges", n.ID)})
+		case inputs[n.ID] == 0:
+			issues = append(issues, structuralIssue{Kind: "no_inputs", Severity: "info", Node: n.ID, Message: fmt.Sprintf("node %s has no inputs and acts as an exogenous driver", n.ID)})
+		case outputs[n.ID] == 0:
+			issues = append(issues, structuralIssue{Kind: "no_outputs", Severity: "info", Node: n.ID, Message: fmt.Sprintf("node %s affects no other node", n.ID)})
+		}
+	}
+
+	cycles, truncated := elementaryCycles(adj, limit)
+	loops := make([]feedbackLoop, 0, len(cycles))
+	inLoops := map[string]int{}
+	for _, c := range cycles {
+		l := feedbackLoop{Length: len(c), Nodes: make([]string, 0, len(c)), DelayEdges: []string{}}
+		unknown := false
+		for i, v := range c {
+			w := c[(i+1)%len(c)]
+			e := edges[[2]int{v, w}]
+			l.Nodes = append(l.Nodes, sys.Nodes[v].ID)
+			inLoops[sys.Nodes[v].ID]++
+			switch e.Polarity {
+			case "-":
+				l.NegativeEdges++
+			case "+":
+			default:
+				unknown = true
+			}
+			if e.Delay {
+				l.DelayEdges = append(l.DelayEdges, delayKey(e.Source, e.Target))
+			}
+			if sys.Nodes[v].Level == "stock" {
+				l.HasStock = true
+			}
+		}
+		l.HasDelay = len(l.DelayEdges) > 0
+		switch {
+		case unknown:
+			l.Polarity = "unknown"
+		case l.NegativeEdges%2 == 0:
+			l.Polarity = "reinforcing"
+		default:
+			l.Polarity = "balancing"
+		}
+		loops = append(loops, l)
+	}
+	sort.SliceStable(loops, func(i, j int) bool {
+		if loops[i].Length != loops[j].Length {
+			return loops[i].Length < loops[j].Length
+		}
+		return strings.Join(loops[i].Nodes, "\x00") < strings.Join(loops[j].Nodes, "\x00")
+	})
+	counts := map[string]int{"reinforcing": 0, "balancing": 0, "unknown": 0}
+	for i := range loops {
+		l := &loops[i]
+		counts[l.Polarity]++
+		l.ID = fmt.Sprintf("%s%d", strings.ToUpper(l.Polarity[:1]), counts[l.Polarity])
+		// A loop through no stock and no delay has no state to break it, so
+		// its values must be solved simultaneously; simulate_system rejects it.
+		if !l.HasStock && !l.HasDelay {
+			issues = append(issues, structuralIssue{Kind: "algebraic_loop", Severity: "error", Loop: l.ID, Message: fmt.Sprintf("loop %s (%s) has no stock or delay", l.ID, strings.Join(l.Nodes, " -> "))})
+		}
+	}
+	if truncated {
+		issues = append(issues, structuralIssue{Kind: "loops_truncated", Severity: "warning", Message: fmt.Sprintf("stopped after %d loops; raise max_loops to enumerate more", limit)})
+	}
+
+	participation := make([]map[string]any, 0, len(sys.Nodes))
+	for _, n := range sys.Nodes {
+		participation = append(participation, map[string]any{"node": n.ID, "loops": inLoops[n.ID], "inputs": inputs[n.ID], "outputs": outputs[n.ID]})
+	}
+	severities := map[string]int{"error": 0, "warning": 1, "info": 2}
+	sort.SliceStable(issues, func(i, j int) bool { return severities[issues[i].Severity] < severities[issues[j].Severity] })
+	return map[string]any{
+		"system_id":   sys.ID,
+		"loops":       loops,
+		"loop_count":  len(loops),
+		"reinforcing": counts["reinforcing"],
+		"balancing":   counts["balancing"],
+		"unknown":     counts["unknown"],
+		"delayed":     countDelayed(loops),
+		"truncated":   truncated,
+		"nodes":       participation,
+		"issues":      issues,
+		"valid":       !hasErrorIssue(issues),
+	}, nil
+}
+
+func countDelayed(loops []feedbackLoop) int {
+	n := 0
+	for _, l := range loops {
+		if l.HasDelay {
+			n++
+		}
+	}
+	return n
+}
+
+func hasErrorIssue(issues []structuralIssue) bool {
+	for _, i := range issues {
+		if i.Severity == "error" {
+			return true
+		}
+	}
+	return false
+}
+
+// elementaryCycles is Johnson's algorithm: for each start vertex s, in index
+// order, it searches the strongly connected component containing s among the
+// vertices >= s, blocking vertices that cannot reach s until a cycle through
+// them is found. Each cycle is returned once, starting at its lowest vertex.
+// It stops after limit cycles and reports whether it did.
+func elementaryCycles(adj [][]int, limit int) ([][]int, bool) {
+	n := len(adj)
+	cycles := make([][]int, 0)
+	blocked := make([]bool, n)
+	blockMap := make([]map[int]bool, n)
+	stack := make([]int, 0)
+	truncated := false
+
+	var unblock func(int)
+	unblock = func(u int) {
+		blocked[u] = false
+		for w := range blockMap[u] {
+			delete(blockMap[u], w)
+			if blocked[w] {
+				unblock(w)
+			}
+		}
+	}
+	var circuit func(v, s int, comp map[int]bool) bool
+	circuit = func(v, s int, comp map[int]bool) bool {
+		found := false
+		stack = append(stack, v)
+		blocked[v] = true
+		for _, w := range adj[v] {
+			if !comp[w] || truncated {
+				continue
+			}
+			if w == s {
+				if len(cycles) >= limit {
+					truncated = true
+					continue
+				}
+				cycles = append(cycles, append([]int(nil), stack...))
+				found = true
+			} else if !blocked[w] && circuit(w, s, comp) {
+				found = true
+			}
+		}
+		if found {
+			unblock(v)
+		} else {
+			for _, w := range adj[v] {
+				if comp[w] {
+					blockMap[w][v] = true
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		return found
+	}
+
+	for s := 0; s < n && !truncated; s++ {
+		comp := componentOf(adj, s)
+		for v := range comp {
+			blocked[v] = false
+			blockMap[v] = map[int]bool{}
+		}
+		circuit(s, s, comp)
+	}
+	return cycles, truncated
+}
+
+// componentOf returns the strongly connected component of s in the subgraph
+// of vertices >= s: the vertices both reachable from s and reaching it.
+func componentOf(adj [][]int, s int) map[int]bool {
+	n := len(adj)
+	reach := func(next func(int) []int) map[int]bool {
+		seen := map[int]bool{s: true}
+		queue := []int{s}
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			for _, w := range next(v) {
+				if w >= s && !seen[w] {
+					seen[w] = true
+					queue = append(queue, w)
+				}
+			}
+		}
+		return seen
+	}
+	rev := make([][]int, n)
+	for v, ws := range adj {
+		for _, w := range ws {
+			rev[w] = append(rev[w], v)
+		}
+	}
+	fwd := reach(func(v int) []int { return adj[v] })
+	back := reach(func(v int) []int { return rev[v] })
+	comp := map[int]bool{}
+	for v := range fwd {
+		if back[v] {
+			comp[v] = true
+		}
+	}
+	return comp
+}
@@ -54,30 +54,44 @@ type ResourceFlow struct {
 	Year         int     `json:"year"`
 	Volume       float64 `json:"volume"`
 	Value        float64 `json:"value"`
+	// ReportedBy is "exporter" or "importer" for mirror trade records; empty
+	// when the flow has a single source. Analyses read flows through
+	// settledFlows, which keeps one side of each mirror pair.
+	ReportedBy string `json:"reportedBy,omitempty"`
+	// Inferred flows are estimates rather than observations: Method names the
+	// estimator and Confidence (0–1) how far the estimate can be trusted.
+	Inferred   bool    `json:"inferred,omitempty"`
+	Method     string  `json:"method,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
 }
 
 type GraphNode struct {
-	ID    string  `json:"id"`
-	Label string  `json:"label"`
-	Type  string  `json:"type"`
-	Value float64 `json:"value"`
-	X     float64 `json:"x"`
-	Y     float64 `json:"y"`
-	Z     float64 `json:"z"`
-	Color string  `json:"color"`
-	Size  float64 `json:"size"`
+	ID           string  `json:"id"`
+	Label        string  `json:"label"`
+	Type         string  `json:"type"`
+	ResourceType string  `json:"resourceType,omitempty"`
+	Value        float64 `json:"value"`
+	X            float64 `json:"x"`
+	Y            float64 `json:"y"`
+	Z            float64 `json:"z"`
+	Color        string  `json:"color"`
+	Size         float64 `json:"size"`
 }
 
 type GraphEdge struct {
-	Source string  `json:"source"`
-	Target string  `json:"target"`
-	Weight float64 `json:"weight"`
-	Color  string  `json:"color"`
+	ID         string  `json:"id"`
+	Source     string  `json:"source"`
+	Target     string  `json:"target"`
+	Type       string  `json:"type"`
+	ResourceID string  `json:"resourceId,omitempty"`
+	Weight     float64 `json:"weight"`
+	Color      string  `json:"color"`
 }
 
 type GraphData struct {
-	Nodes []GraphNode `json:"nodes"`
-	Edges []GraphEdge `json:"edges"`
+	Nodes  []GraphNode `json:"nodes"`
+	Edges  []GraphEdge `json:"edges"`
+	Layout *LayoutInfo `json:"layout,omitempty"`
 }
 
 type SystemNode struct {
@@ -178,12 +192,23 @@ var (
 	}
 	tools = []mcpTool{
 		{Name: "global.list_resources", Description: "List global resources", InputSchema: map[string]any{"type": "object", "properties": map[string]any{}}},
-		{Name: "global.list_flows", Description: "List resource flows", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"resource_id": map[string]any{"type": "string"}, "year": map[string]any{"type": "integer"}}}},
-		{Name: "global.get_graph", Description: "Build resource graph", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"resource_id": map[string]any{"type": "string"}, "year": map[string]any{"type": "integer"}}}},
+		{Name: "global.list_flows", Description: "List resource flows", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"resource_id": map[string]any{"type": "string"}, "year": map[string]any{"type": "integer"}, "include_inferred": map[string]any{"type": "boolean", "description": "Append flows stored by global.infer_flows; observed flows are dropped where the stored set was inferred without them"}}}},
+		{Name: "global.infer_flows", Description: "Estimate the bilateral flow matrix of a resource from region export/import totals (production − consumption where no trade is reported) by iterative proportional fitting (RAS) on a uniform or gravity seed; inferred flows carry method and confidence", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"resource_id": map[string]any{"type": "string"}, "year": map[string]any{"type": "integer", "description": "Default: latest year with stats"}, "method": map[string]any{"type": "string", "enum": inferenceMethods, "description": "Default: gravity"}, "distance_decay": map[string]any{"type": "number", "description": "Gravity distance exponent (default 1)"}, "balance": map[string]any{"type": "string", "enum": []string{"rest_of_world", "scale"}, "description": "How unequal export and import totals are matched (default: rest_of_world)"}, "keep_observed": map[string]any{"type": "boolean", "description": "Hold observed flows fixed and infer only the remainder (default true)"}, "store": map[string]any{"type": "boolean", "description": "Store the inferred flows, replacing earlier ones for the resource and year (default true)"}}, "required": []string{"resource_id"}}},
+		{Name: "global.get_graph", Description: "Build the multi-layer resource graph: region and resource nodes sized by production/consumption, flow and production/consumption edges, optional resource-type nodes", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"resource_id": map[string]any{"type": "string", "description": "Default: all resources"}, "year": map[string]any{"type": "integer", "description": "Default: latest year with data"}, "weight_by": map[string]any{"type": "string", "enum": []string{"volume", "value"}, "description": "Flow edge weight (default: volume)"}, "size_by": map[string]any{"type": "string", "enum": []string{"production", "consumption", "throughput"}, "description": "Node value measure (default: throughput = production + consumption)"}, "include_types": map[string]any{"type": "boolean", "description": "Add resource-type nodes"}, "layout": map[string]any{"type": "string", "enum": []string{"force", "geo", "none"}, "description": "Node positions: seeded 3D force-directed (default), regions on a sphere by lat/lng, or none"}, "seed": map[string]any{"type": "integer", "description": "Force layout seed (default 1)"}, "iterations": map[string]any{"type": "integer", "description": "Force layout iterations (default 300, max 2000)"}, "format": map[string]any{"type": "string", "enum": []string{"json", "graphml", "gexf", "dot", "cytoscape"}, "description": "Default: json"}}}},
 		{Name: "global.get_resource_stats", Description: "Get region resource stats", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"resource_id": map[string]any{"type": "string"}}}},
 		{Name: "global.get_timeline", Description: "Get timeline data", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"resource_id": map[string]any{"type": "string"}}}},
+		{Name: "global.get_geojson", Description: "Get region stats and flows for a resource as a GeoJSON FeatureCollection", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"resource_id": map[string]any{"type": "string"}, "year": map[string]any{"type": "integer", "description": "Default: latest year with stats"}, "include_flows": map[string]any{"type": "boolean", "description": "Default: true"}, "great_circle": map[string]any{"type": "boolean", "description": "Draw flows as great-circle arcs"}, "segments": map[string]any{"type": "integer", "description": "Arc segments (default 32, max 256)"}}, "required": []string{"resource_id"}}},
+		{Name: "global.analyze_graph", Description: "Rank regions of the flow graph by chokepoint score: weighted degree, betweenness, PageRank and flow centrality, with articulation points and bridges", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"resource_id": map[string]any{"type": "string", "description": "Default: all resources (value-weighted)"}, "year": map[string]any{"type": "integer", "description": "Default: latest year with data"}, "weight_by": map[string]any{"type": "string", "enum": []string{"volume", "value"}, "description": "Default: volume for one resource, value across resources"}, "rank_by": map[string]any{"type": "string", "enum": rankMeasures, "description": "Default: score"}}}},
+		{Name: "global.find_supply_paths", Description: "Find the k best supplier paths to a consuming region over the flows of a resource, ranked by bottleneck capacity or summed unit cost, with optional excluded regions and edges", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"resource_id": map[string]any{"type": "string"}, "region_id": map[string]any{"type": "string", "description": "Consuming region"}, "year": map[string]any{"type": "integer", "description": "Default: latest year with data"}, "k": map[string]any{"type": "integer", "description": "Paths to return (default 3, max 20)"}, "rank_by": map[string]any{"type": "string", "enum": []string{"capacity", "cost"}, "description": "Default: capacity"}, "max_hops": map[string]any{"type": "integer", "description": "Default 4, max 8"}, "exclude_regions": map[string]any{"type": "array", "items": map[string]any{"type": "string"}}, "exclude_edges": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Flow IDs or source->target pairs"}}, "required": []string{"resource_id", "region_id"}}},
+		{Name: "global.max_flow", Description: "Maximum volume the producing regions can deliver to a region through the flows of a resource, with the minimum cut of edges that limits it", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"resource_id": map[string]any{"type": "string"}, "region_id": map[string]any{"type": "string", "description": "Consuming region (sink)"}, "year": map[string]any{"type": "integer", "description": "Default: latest year with data"}, "sources": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Source regions (default: regions with production that year)"}}, "required": []string{"resource_id", "region_id"}}},
+		{Name: "global.supply_risk", Description: "Supply risk indices per resource and region: production HHI, net import dependency, reserve-to-production and composite scores with their inputs", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"resource_id": map[string]any{"type": "string", "description": "Default: every resource with stats"}, "year": map[string]any{"type": "integer", "description": "Default: latest year with stats per resource"}}}},
+		{Name: "global.simulate_disruption", Description: "Remove or scale down regions or flows, propagate the shortfall through the flow network, redistribute from spare capacity and report supply gaps, affected flows and risk deltas; scenarios can be saved and re-run by ID", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"scenario_id": map[string]any{"type": "string", "description": "Re-run a saved scenario; other arguments are ignored"}, "name": map[string]any{"type": "string"}, "resource_id": map[string]any{"type": "string", "description": "Default resource for the disruptions"}, "year": map[string]any{"type": "integer", "description": "Default: latest year with data per resource"}, "disruptions": map[string]any{"type": "array", "items": map[string]any{"type": "object", "properties": map[string]any{"resource_id": map[string]any{"type": "string"}, "region_id": map[string]any{"type": "string"}, "flow_id": map[string]any{"type": "string"}, "scope": map[string]any{"type": "string", "enum": disruptionScopes, "description": "Region disruptions (default: exports)"}, "change": map[string]any{"type": "number", "description": "Fractional change between -1 (removed, default) and 0"}}}}, "redistribution": map[string]any{"type": "string", "enum": []string{"proportional", "none"}, "description": "Default: proportional"}, "surge": map[string]any{"type": "number", "description": "Extra output undisrupted producers can add, as a share of production (default 0.1)"}, "save": map[string]any{"type": "boolean", "description": "Save the scenario for re-running by ID"}}}},
+		{Name: "global.list_scenarios", Description: "List saved disruption scenarios", InputSchema: map[string]any{"type": "object", "properties": map[string]any{}}},
+		{Name: "global.reconcile_trade", Description: "Reconcile RegionStats export/import totals with bilateral flows: per-region gaps and coverage, mirror-record asymmetries and optional rest-of-world residual flows", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"resource_id": map[string]any{"type": "string", "description": "Default: every resource with stats"}, "year": map[string]any{"type": "integer", "description": "Default: latest year with data per resource"}, "tolerance": map[string]any{"type": "number", "description": "Relative gap or asymmetry accepted as ok (default 0.05)"}, "mirror_preference": map[string]any{"type": "string", "enum": mirrorPreferences, "description": "Report used when both sides report a flow (default: importer)"}, "include_rest_of_world": map[string]any{"type": "boolean", "description": "Add residual flows to and from the rest of the world"}}}},
 		{Name: "global.list_systems", Description: "List system models", InputSchema: map[string]any{"type": "object", "properties": map[string]any{}}},
 		{Name: "global.get_system", Description: "Get system model", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"system_id": map[string]any{"type": "string"}}, "required": []string{"system_id"}}},
+		{Name: "global.analyze_system", Description: "Enumerate the feedback loops (elementary cycles) of a system model, classify each as reinforcing or balancing from edge polarities, mark loops with delays, and flag structural issues such as missing nodes, nodes without inputs and algebraic loops", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"system_id": map[string]any{"type": "string"}, "max_loops": map[string]any{"type": "integer", "description": "Stop enumerating after this many loops (default 1000, max 10000)"}}, "required": []string{"system_id"}}},
+		{Name: "global.simulate_system", Description: "Integrate a system model over time with Euler or RK4: stock equations are net rates, flows and auxiliaries are evaluated in dependency order, and Delay edges read a first-order smoothed or pipeline-delayed value", InputSchema: map[string]any{"type": "object", "properties": map[string]any{"system_id": map[string]any{"type": "string"}, "equations": map[string]any{"type": "object", "description": "Node ID to expression, overlaying the model defaults; names are nodes, parameters or time"}, "initial": map[string]any{"type": "object", "description": "Initial values of stocks and delayed sources"}, "parameters": map[string]any{"type": "object", "description": "Named constants used by the equations"}, "delays": map[string]any{"type": "object", "description": "Delay time per \"source->target\" edge"}, "default_delay": map[string]any{"type": "number", "description": "Delay time of Delay edges without one (default 1)"}, "delay_type": map[string]any{"type": "string", "enum": []string{firstOrderDelayType, pipelineDelayType}, "description": "Default: first_order"}, "method": map[string]any{"type": "string", "enum": []string{"euler", "rk4"}, "description": "Default: rk4"}, "t_start": map[string]any{"type": "number", "description": "Default 0"}, "t_end": map[string]any{"type": "number", "description": "Default: model default"}, "dt": map[string]any{"type": "number", "description": "Default: model default; at most 10000 steps"}}, "required": []string{"system_id"}}},
 	}
 )
 
@@ -194,7 +219,19 @@ func init() {
 func main() {}
 
 func routeHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	tc := traceFromRequest(r)
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	w = rec
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Trace-Id", tc.TraceID)
+	w.Header().Set("traceresponse", tc.header())
+	fields := map[string]any{"method": r.Method, "path": r.URL.Path}
+	defer func() {
+		fields["status"] = rec.status
+		fields["duration_ms"] = time.Since(start).Milliseconds()
+		logEvent(tc, "info", "request", fields)
+	}()
 	if handleCORS(w, r) {
 		return
 	}
@@ -205,6 +242,9 @@ func routeHandler(w http.ResponseWriter, r *http.Request) {
 	case path == "/healthz":
 		writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "service": "global-mcp-component"})
 		return
+	case path == "/readyz":
+		handleReadyz(w, r)
+		return
 	case path == "/api/":
 		writeJSON(w, http.StatusGone, map[string]string{"error": "legacy REST APIs are removed", "detail": "Use MCP endpoint /api/mcp with tools/list and tools/call"})
 		return
@@ -232,8 +272,10 @@ func routeHandler(w http.ResponseWriter, r *http.Request) {
 	case "tools/list":
 		writeJSON(w, http.StatusOK, mcpResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{"tools": tools}})
 	case "tools/call":
+		fields["tool"] = req.Params.Name
 		result, err := callTool(req.Params.Name, req.Params.Arguments)
 		if err != nil {
+			fields["error"] = err.Error()
 			writeJSON(w, http.StatusOK, mcpResponse{JSONRPC: "2.0", ID: req.ID, Error: &mcpError{Code: -32000, Message: err.Error()}})
 			return
 		}
@@ -254,6 +296,9 @@ func normalizePath(raw string) string {
 	if len(parts) >= 2 && parts[1] == "healthz" {
 		return "/healthz"
 	}
+	if len(parts) >= 2 && parts[1] == "readyz" {
+		return "/readyz"
+	}
 	if len(parts) >= 2 && parts[1] == "api" && len(parts) == 2 {
 		return "/api/"
 	}
@@ -270,6 +315,7 @@ func callTool(name string, args map[string]any) (any, error) {
 	case "global.list_flows":
 		resourceID, _ := args["resource_id"].(string)
 		year := toInt(args["year"])
+		includeInferred, _ := args["include_inferred"].(bool)
 		out := make([]ResourceFlow, 0)
 		for _, f := range flows {
 			if resourceID != "" && f.ResourceID != resourceID {
@@ -278,16 +324,35 @@ func callTool(name string, args map[string]any) (any, error) {
 			if year > 0 && f.Year != year {
 				continue
 			}
+			// A stored set fitted without the observed flows already covers them.
+			if includeInferred && inferredReplacesObserved(f.ResourceID, f.Year) {
+				continue
+			}
 			out = append(out, f)
 		}
+		if includeInferred {
+			out = append(out, storedInferredFlows(resourceID, year)...)
+		}
 		return map[string]any{"flows": out, "count": len(out)}, nil
+	case "global.infer_flows":
+		return inferFlows(args)
 	case "global.get_graph":
-		resourceID, _ := args["resource_id"].(string)
-		year := toInt(args["year"])
-		if year == 0 {
-			year = time.Now().Year()
+		opts, err := graphOptionsFromArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		layout, err := layoutOptionsFromArgs(args)
+		if err != nil {
+			return nil, err
 		}
-		return buildGraph(resourceID, year), nil
+		graph := buildGraph(opts)
+		graph.Layout = applyLayout(&graph, layout)
+		format, _ := args["format"].(string)
+		title := opts.ResourceID
+		if title == "" {
+			title = "all-resources"
+		}
+		return exportGraph(graph, format, fmt.Sprintf("%s-%d", title, opts.Year))
 	case "global.get_resource_stats":
 		resourceID, _ := args["resource_id"].(string)
 		if resourceID == "" {
@@ -305,6 +370,29 @@ func callTool(name string, args map[string]any) (any, error) {
 		}
 		sort.Slice(entries, func(i, j int) bool { return entries[i].Year < entries[j].Year })
 		return map[string]any{"resource_id": resourceID, "timeline": entries, "count": len(entries)}, nil
+	case "global.get_geojson":
+		resourceID, _ := args["resource_id"].(string)
+		includeFlows := true
+		if v, ok := args["include_flows"].(bool); ok {
+			includeFlows = v
+		}
+		greatCircle, _ := args["great_circle"].(bool)
+		return buildGeoJSON(resourceID, toInt(args["year"]), includeFlows, greatCircle, toInt(args["segments"]))
+	case "global.analyze_graph":
+		return analyzeGraph(args)
+	case "global.find_supply_paths":
+		return findSupplyPaths(args)
+	case "global.max_flow":
+		return maxSupplyFlow(args)
+	case "global.supply_risk":
+		return supplyRisk(args)
+	case "global.simulate_disruption":
+		return simulateDisruption(args)
+	case "global.list_scenarios":
+		scenarios := listScenarios()
+		return map[string]any{"scenarios": scenarios, "count": len(scenarios)}, nil
+	case "global.reconcile_trade":
+		return reconcileTrade(args)
 	case "global.list_systems":
 		index := make([]map[string]string, 0, len(systems))
 		for _, s := range systems {
@@ -319,41 +407,15 @@ func callTool(name string, args map[string]any) (any, error) {
 			}
 		}
 		return nil, fmt.Errorf("system not found: %s", systemID)
+	case "global.analyze_system":
+		return analyzeSystem(args)
+	case "global.simulate_system":
+		return simulateSystem(args)
 	default:
 		return nil, fmt.Errorf("unknown tool: %s", name)
 	}
 }
 
-func buildGraph(resourceID string, year int) GraphData {
-	nodes := map[string]GraphNode{}
-	edges := make([]GraphEdge, 0)
-
-	for _, f := range flows {
-		if resourceID != "" && f.ResourceID != resourceID {
-			continue
-		}
-		if year > 0 && f.Year != year {
-			continue
-		}
-		sid := "region:" + f.SourceRegion
-		tid := "region:" + f.TargetRegion
-		if _, ok := nodes[sid]; !ok {
-			nodes[sid] = GraphNode{ID: sid, Label: strings.ToUpper(f.SourceRegion), Type: "region", Value: 1, Color: "#3b82f6", Size: 14}
-		}
-		if _, ok := nodes[tid]; !ok {
-			nodes[tid] = GraphNode{ID: tid, Label: strings.ToUpper(f.TargetRegion), Type: "region", Value: 1, Color: "#10b981", Size: 14}
-		}
-		edges = append(edges, GraphEdge{Source: sid, Target: tid, Weight: f.Volume, Color: "#9ca3af"})
-	}
-
-	outNodes := make([]GraphNode, 0, len(nodes))
-	for _, n := range nodes {
-		outNodes = append(outNodes, n)
-	}
-	sort.Slice(outNodes, func(i, j int) bool { return outNodes[i].ID < outNodes[j].ID })
-	return GraphData{Nodes: outNodes, Edges: edges}
-}
-
 func toInt(v any) int {
 	switch t := v.(type) {
 	case int:
@@ -370,10 +432,22 @@ func toInt(v any) int {
 	}
 }
 
+func toStringSlice(v any) []string {
+	raw, _ := v.([]any)
+	out := make([]string, 0, len(raw))
+	for _, x := range raw {
+		if s, ok := x.(string); ok && strings.TrimSpace(s) != "" {
+			out = append(out, strings.TrimSpace(s))
+		}
+	}
+	return out
+}
+
 func handleCORS(w http.ResponseWriter, r *http.Request) bool {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization,traceparent")
+	w.Header().Set("Access-Control-Expose-Headers", "X-Trace-Id,traceresponse")
 	if r.Method == http.MethodOptions {
 		w.WriteHeader(http.StatusNoContent)
 		return true
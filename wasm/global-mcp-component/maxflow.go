@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// superSource is the node added in front of the producing regions so a
+// multi-source max flow runs as a single-source one.
+const superSource = "__source__"
+
+type cutEdge struct {
+	Source   string   `json:"source"`
+	Target   string   `json:"target"`
+	Capacity float64  `json:"capacity"`
+	Share    float64  `json:"share"`
+	FlowIDs  []string `json:"flowIds"`
+}
+
+type assignedFlow struct {
+	Source   string  `json:"source"`
+	Target   string  `json:"target"`
+	Flow     float64 `json:"flow"`
+	Capacity float64 `json:"capacity"`
+}
+
+// maxSupplyFlow computes the largest volume the producing regions can deliver
+// to a sink region through the recorded flows of one resource, with flow
+// volumes as edge capacities, and a minimum cut of edges that bounds it.
+func maxSupplyFlow(args map[string]any) (any, error) {
+	resourceID, _ := args["resource_id"].(string)
+	sink, _ := args["region_id"].(string)
+	if resourceByID(resourceID) == nil {
+		return nil, fmt.Errorf("resource not found: %s", resourceID)
+	}
+	if sink == "" {
+		return nil, fmt.Errorf("region_id is required")
+	}
+	year := toInt(args["year"])
+	if year == 0 {
+		year = latestDataYear(resourceID)
+	}
+	net := buildFlowNetwork(resourceID, year, "volume")
+	t, ok := net.Index[sink]
+	if !ok {
+		return nil, fmt.Errorf("no %s flows into or out of region %s in %d", resourceID, sink, year)
+	}
+
+	sources := toStringSlice(args["sources"])
+	if len(sources) == 0 {
+		for _, s := range resourceStats[resourceID] {
+			if s.Year == year && s.Production > 0 && !containsString(sources, s.RegionID) {
+				sources = append(sources, s.RegionID)
+			}
+		}
+	}
+	sort.Strings(sources)
+	used := make([]string, 0, len(sources))
+	ignored := make([]string, 0)
+	for _, id := range sources {
+		if _, ok := net.Index[id]; ok && id != sink {
+			used = append(used, id)
+		} else {
+			ignored = append(ignored, id)
+		}
+	}
+
+	// Extend the network with the super source; its edges are wider than the
+	// whole network, so they never appear in a minimum cut.
+	n := len(net.Nodes)
+	ext := &flowNetwork{ResourceID: net.ResourceID, Year: net.Year, WeightBy: net.WeightBy, Nodes: append(append([]string(nil), net.Nodes...), superSource), Index: map[string]int{}}
+	for i, id := range ext.Nodes {
+		ext.Index[id] = i
+	}
+	wide := net.total() + 1
+	ext.Cap = make([][]float64, n+1)
+	for i := range ext.Cap {
+		ext.Cap[i] = make([]float64, n+1)
+		if i < n {
+			copy(ext.Cap[i], net.Cap[i])
+		}
+	}
+	for _, id := range used {
+		ext.Cap[n][net.Index[id]] = wide
+	}
+
+	value, flow, reach := 0.0, [][]float64(nil), []bool(nil)
+	if len(used) > 0 {
+		value, flow, reach = ext.maxFlow(n, t, nil)
+	}
+
+	flowIDs := map[[2]string][]string{}
+	for _, f := range settledFlows() {
+		if f.ResourceID == resourceID && f.Year == year && f.SourceRegion != f.TargetRegion {
+			key := [2]string{f.SourceRegion, f.TargetRegion}
+			flowIDs[key] = append(flowIDs[key], f.ID)
+		}
+	}
+	cut := make([]cutEdge, 0)
+	edges := make([]assignedFlow, 0)
+	contributions := map[string]float64{}
+	inbound := 0.0
+	for i := 0; i < n; i++ {
+		inbound += net.Cap[i][t]
+		for j := 0; j < n; j++ {
+			if net.Cap[i][j] <= 0 {
+				continue
+			}
+			if reach != nil && reach[i] && !reach[j] {
+				share := 0.0
+				if value > 0 {
+					share = round6(net.Cap[i][j] / value)
+				}
+				cut = append(cut, cutEdge{Source: net.Nodes[i], Target: net.Nodes[j], Capacity: round6(net.Cap[i][j]), Share: share, FlowIDs: flowIDs[[2]string{net.Nodes[i], net.Nodes[j]}]})
+			}
+			if flow != nil && flow[i][j] > 0 {
+				edges = append(edges, assignedFlow{Source: net.Nodes[i], Target: net.Nodes[j], Flow: round6(flow[i][j]), Capacity: round6(net.Cap[i][j])})
+			}
+		}
+	}
+	for _, id := range used {
+		if flow != nil {
+			contributions[id] = round6(flow[n][net.Index[id]])
+		}
+	}
+	sort.SliceStable(cut, func(i, j int) bool { return cut[i].Capacity > cut[j].Capacity })
+
+	out := map[string]any{
+		"resource_id":      resourceID,
+		"region_id":        sink,
+		"region_name":      regionName(sink),
+		"year":             year,
+		"unit":             resourceByID(resourceID).Unit,
+		"sources":          used,
+		"ignored_sources":  ignored,
+		"max_flow":         round6(value),
+		"inbound_capacity": round6(inbound),
+		"min_cut":          cut,
+		"cut_size":         len(cut),
+		"contributions":    contributions,
+		"edge_flows":       edges,
+	}
+	// The largest cut edge is the single link whose loss removes the most
+	// deliverable volume; a one-edge cut means one link carries everything.
+	if len(cut) > 0 {
+		out["largest_cut_share"] = cut[0].Share
+	}
+	for _, s := range resourceStats[resourceID] {
+		if s.Year == year && s.RegionID == sink {
+			if s.Import > 0 {
+				out["import_coverage"] = round6(value / s.Import)
+			}
+			if s.Consumption > 0 {
+				out["consumption_coverage"] = round6(value / s.Consumption)
+			}
+		}
+	}
+	return out, nil
+}
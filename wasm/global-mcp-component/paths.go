@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultPathCount = 3
+	maxPathCount     = 20
+	defaultPathHops  = 4
+	maxPathHops      = 8
+	// maxPathCandidates bounds the simple-path enumeration on dense graphs.
+	maxPathCandidates = 10000
+)
+
+// pathEdge is one hop of a supply path: all flows of the resource between
+// two regions in the year, summed. UnitCost is value per volume unit.
+type pathEdge struct {
+	Source   string   `json:"source"`
+	Target   string   `json:"target"`
+	Volume   float64  `json:"volume"`
+	Value    float64  `json:"value"`
+	UnitCost float64  `json:"unitCost"`
+	FlowIDs  []string `json:"flowIds"`
+}
+
+// supplyPath is a route from a supplier to the consuming region. Capacity is
+// the smallest hop volume, Cost the sum of the hops' unit costs.
+type supplyPath struct {
+	Rank         int        `json:"rank"`
+	Supplier     string     `json:"supplier"`
+	SupplierName string     `json:"supplierName"`
+	Regions      []string   `json:"regions"`
+	Hops         int        `json:"hops"`
+	Capacity     float64    `json:"capacity"`
+	Cost         float64    `json:"cost"`
+	Bottleneck   string     `json:"bottleneck"`
+	Edges        []pathEdge `json:"edges"`
+}
+
+// findSupplyPaths returns the k best supplier→region paths over the flows of
+// one resource, ranked by bottleneck capacity or by summed unit cost.
+// Suppliers are the regions producing the resource that year, or every region
+// in the flows when the year has no stats.
+func findSupplyPaths(args map[string]any) (any, error) {
+	resourceID, _ := args["resource_id"].(string)
+	regionID, _ := args["region_id"].(string)
+	if resourceByID(resourceID) == nil {
+		return nil, fmt.Errorf("resource not found: %s", resourceID)
+	}
+	if regionID == "" {
+		return nil, fmt.Errorf("region_id is required")
+	}
+	rankBy, _ := args["rank_by"].(string)
+	if rankBy == "" {
+		rankBy = "capacity"
+	}
+	if rankBy != "capacity" && rankBy != "cost" {
+		return nil, fmt.Errorf("rank_by must be capacity or cost")
+	}
+	k := toInt(args["k"])
+	if k <= 0 {
+		k = defaultPathCount
+	}
+	k = min(k, maxPathCount)
+	maxHops := toInt(args["max_hops"])
+	if maxHops <= 0 {
+		maxHops = defaultPathHops
+	}
+	maxHops = min(maxHops, maxPathHops)
+	year := toInt(args["year"])
+	if year == 0 {
+		year = latestDataYear(resourceID)
+	}
+	excludeRegions := toStringSlice(args["exclude_regions"])
+	excludeEdges := toStringSlice(args["exclude_edges"])
+	if containsString(excludeRegions, regionID) {
+		return nil, fmt.Errorf("region %s is both the destination and excluded", regionID)
+	}
+
+	// Incoming hops per target region, after exclusions.
+	edges := map[[2]string]*pathEdge{}
+	known := map[string]bool{}
+	for _, f := range settledFlows() {
+		if f.ResourceID != resourceID || f.Year != year || f.SourceRegion == f.TargetRegion {
+			continue
+		}
+		known[f.SourceRegion], known[f.TargetRegion] = true, true
+		if containsString(excludeRegions, f.SourceRegion) || containsString(excludeRegions, f.TargetRegion) ||
+			containsString(excludeEdges, f.ID) || containsString(excludeEdges, f.SourceRegion+"->"+f.TargetRegion) {
+			continue
+		}
+		key := [2]string{f.SourceRegion, f.TargetRegion}
+		e := edges[key]
+		if e == nil {
+			e = &pathEdge{Source: f.SourceRegion, Target: f.TargetRegion}
+			edges[key] = e
+		}
+		e.Volume += f.Volume
+		e.Value += f.Value
+		e.FlowIDs = append(e.FlowIDs, f.ID)
+	}
+	inbound := map[string][]*pathEdge{}
+	for _, e := range edges {
+		if e.Volume <= 0 {
+			continue
+		}
+		e.UnitCost = round6(e.Value / e.Volume)
+		inbound[e.Target] = append(inbound[e.Target], e)
+	}
+	for _, list := range inbound {
+		sort.Slice(list, func(i, j int) bool { return list[i].Source < list[j].Source })
+	}
+
+	if !known[regionID] && !hasStatsFor(resourceID, year, regionID) {
+		return nil, fmt.Errorf("no %s flows or stats for region %s in %d", resourceID, regionID, year)
+	}
+	suppliers := make([]string, 0)
+	for _, s := range supplyPathSuppliers(resourceID, year, regionID, known) {
+		if !containsString(excludeRegions, s) {
+			suppliers = append(suppliers, s)
+		}
+	}
+
+	// Walk upstream from the destination; every supplier reached closes a path.
+	candidates := make([]supplyPath, 0)
+	truncated := false
+	visited := map[string]bool{regionID: true}
+	hops := make([]*pathEdge, 0, maxHops)
+	var walk func(node string)
+	walk = func(node string) {
+		if len(candidates) >= maxPathCandidates {
+			truncated = true
+			return
+		}
+		if len(hops) > 0 && containsString(suppliers, node) {
+			candidates = append(candidates, newSupplyPath(hops))
+		}
+		if len(hops) == maxHops {
+			return
+		}
+		for _, e := range inbound[node] {
+			if visited[e.Source] {
+				continue
+			}
+			visited[e.Source] = true
+			hops = append(hops, e)
+			walk(e.Source)
+			hops = hops[:len(hops)-1]
+			visited[e.Source] = false
+		}
+	}
+	walk(regionID)
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if rankBy == "cost" && a.Cost != b.Cost {
+			return a.Cost < b.Cost
+		}
+		if a.Capacity != b.Capacity {
+			return a.Capacity > b.Capacity
+		}
+		if a.Cost != b.Cost {
+			return a.Cost < b.Cost
+		}
+		if a.Hops != b.Hops {
+			return a.Hops < b.Hops
+		}
+		return strings.Join(a.Regions, ">") < strings.Join(b.Regions, ">")
+	})
+	reached := map[string]bool{}
+	for _, p := range candidates {
+		reached[p.Supplier] = true
+	}
+	unreachable := make([]string, 0)
+	for _, s := range suppliers {
+		if !reached[s] {
+			unreachable = append(unreachable, s)
+		}
+	}
+	paths := candidates[:min(k, len(candidates))]
+	for i := range paths {
+		paths[i].Rank = i + 1
+	}
+	return map[string]any{
+		"resource_id":           resourceID,
+		"region_id":             regionID,
+		"region_name":           regionName(regionID),
+		"year":                  year,
+		"rank_by":               rankBy,
+		"k":                     k,
+		"max_hops":              maxHops,
+		"excluded":              map[string][]string{"regions": excludeRegions, "edges": excludeEdges},
+		"suppliers":             suppliers,
+		"unreachable_suppliers": unreachable,
+		"candidates":            len(candidates),
+		"truncated":             truncated,
+		"paths":                 paths,
+		"count":                 len(paths),
+	}, nil
+}
+
+// newSupplyPath builds a path from hops collected destination-first.
+func newSupplyPath(hops []*pathEdge) supplyPath {
+	p := supplyPath{Hops: len(hops), Capacity: math.Inf(1)}
+	for i := len(hops) - 1; i >= 0; i-- {
+		e := *hops[i]
+		e.FlowIDs = append([]string(nil), e.FlowIDs...)
+		p.Edges = append(p.Edges, e)
+		if e.Volume < p.Capacity {
+			p.Capacity = e.Volume
+			p.Bottleneck = e.Source + "->" + e.Target
+		}
+		p.Cost += e.UnitCost
+	}
+	p.Supplier = p.Edges[0].Source
+	p.SupplierName = regionName(p.Supplier)
+	p.Regions = append(p.Regions, p.Supplier)
+	for _, e := range p.Edges {
+		p.Regions = append(p.Regions, e.Target)
+	}
+	p.Capacity = round6(p.Capacity)
+	p.Cost = round6(p.Cost)
+	return p
+}
+
+// supplyPathSuppliers lists the producing regions other than the destination,
+// falling back to every region seen in flows when the year has no stats.
+func supplyPathSuppliers(resourceID string, year int, regionID string, known map[string]bool) []string {
+	out := make([]string, 0)
+	hasStats := false
+	for _, s := range resourceStats[resourceID] {
+		if s.Year != year {
+			continue
+		}
+		hasStats = true
+		if s.Production > 0 && s.RegionID != regionID {
+			out = append(out, s.RegionID)
+		}
+	}
+	if !hasStats {
+		for id := range known {
+			if id != regionID {
+				out = append(out, id)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func hasStatsFor(resourceID string, year int, regionID string) bool {
+	for _, s := range resourceStats[resourceID] {
+		if s.Year == year && s.RegionID == regionID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type readinessCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// handleReadyz verifies the in-memory datasets are loaded and internally
+// consistent before the component accepts MCP traffic.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := []readinessCheck{
+		checkResources(),
+		checkResourceStats(),
+		checkFlows(),
+		checkSystems(),
+	}
+	status, code := "ready", http.StatusOK
+	for _, c := range checks {
+		if !c.OK {
+			status, code = "not_ready", http.StatusServiceUnavailable
+			break
+		}
+	}
+	writeJSON(w, code, map[string]any{"status": status, "service": "global-mcp-component", "checks": checks})
+}
+
+func knownResources() map[string]bool {
+	known := make(map[string]bool, len(resources))
+	for _, res := range resources {
+		known[res.ID] = true
+	}
+	return known
+}
+
+func checkResources() readinessCheck {
+	c := readinessCheck{Name: "resources"}
+	if len(resources) == 0 {
+		c.Detail = "no resources loaded"
+		return c
+	}
+	seen := map[string]bool{}
+	for _, res := range resources {
+		if res.ID == "" || seen[res.ID] {
+			c.Detail = fmt.Sprintf("missing or duplicate resource id %q", res.ID)
+			return c
+		}
+		seen[res.ID] = true
+	}
+	c.OK = true
+	c.Detail = fmt.Sprintf("%d resources", len(resources))
+	return c
+}
+
+func checkResourceStats() readinessCheck {
+	c := readinessCheck{Name: "resource_stats"}
+	known := knownResources()
+	count := 0
+	for resourceID, stats := range resourceStats {
+		if !known[resourceID] {
+			c.Detail = fmt.Sprintf("stats for unknown resource %q", resourceID)
+			return c
+		}
+		for _, s := range stats {
+			if s.Lat < -90 || s.Lat > 90 || s.Lng < -180 || s.Lng > 180 {
+				c.Detail = fmt.Sprintf("%s/%s has out-of-range coordinates", resourceID, s.RegionID)
+				return c
+			}
+		}
+		count += len(stats)
+	}
+	c.OK = true
+	c.Detail = fmt.Sprintf("%d region stats", count)
+	return c
+}
+
+func checkFlows() readinessCheck {
+	c := readinessCheck{Name: "flows"}
+	known := knownResources()
+	for _, f := range flows {
+		if !known[f.ResourceID] {
+			c.Detail = fmt.Sprintf("flow %s references unknown resource %q", f.ID, f.ResourceID)
+			return c
+		}
+		if f.SourceRegion == "" || f.TargetRegion == "" {
+			c.Detail = fmt.Sprintf("flow %s is missing a region", f.ID)
+			return c
+		}
+		if f.ReportedBy != "" && f.ReportedBy != "exporter" && f.ReportedBy != "importer" {
+			c.Detail = fmt.Sprintf("flow %s has unknown reporter %q", f.ID, f.ReportedBy)
+			return c
+		}
+	}
+	c.OK = true
+	c.Detail = fmt.Sprintf("%d flows", len(flows))
+	return c
+}
+
+func checkSystems() readinessCheck {
+	c := readinessCheck{Name: "systems"}
+	for _, sys := range systems {
+		nodes := map[string]bool{}
+		for _, n := range sys.Nodes {
+			nodes[n.ID] = true
+		}
+		for _, e := range sys.Edges {
+			if !nodes[e.Source] || !nodes[e.Target] {
+				c.Detail = fmt.Sprintf("system %s edge %s->%s references a missing node", sys.ID, e.Source, e.Target)
+				return c
+			}
+		}
+	}
+	c.OK = true
+	c.Detail = fmt.Sprintf("%d systems", len(systems))
+	return c
+}
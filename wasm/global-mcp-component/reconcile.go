@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	defaultReconcileTolerance = 0.05
+	defaultMirrorPreference   = "importer"
+	restOfWorld               = "row"
+)
+
+var mirrorPreferences = []string{"importer", "exporter", "mean"}
+
+// settledFlows returns the flows every analysis works on: mirror pairs are
+// settled with the default preference so each trade is counted once.
+func settledFlows() []ResourceFlow {
+	return settleMirrors(flows, defaultMirrorPreference)
+}
+
+// settleMirrors keeps one side of every pair reported by both exporter and
+// importer: the preferred side's records, or with "mean" the importer's
+// records scaled to the mean of both reports. Flows without a reporter and
+// pairs reported by one side only pass through unchanged.
+func settleMirrors(list []ResourceFlow, preference string) []ResourceFlow {
+	type pairKey struct {
+		resource       string
+		year           int
+		source, target string
+	}
+	reported := map[pairKey][2]float64{}
+	for _, f := range list {
+		k := pairKey{f.ResourceID, f.Year, f.SourceRegion, f.TargetRegion}
+		r := reported[k]
+		switch f.ReportedBy {
+		case "exporter":
+			r[0] += f.Volume
+		case "importer":
+			r[1] += f.Volume
+		}
+		reported[k] = r
+	}
+	out := make([]ResourceFlow, 0, len(list))
+	for _, f := range list {
+		r := reported[pairKey{f.ResourceID, f.Year, f.SourceRegion, f.TargetRegion}]
+		if f.ReportedBy == "" || r[0] <= 0 || r[1] <= 0 {
+			out = append(out, f)
+			continue
+		}
+		switch preference {
+		case "exporter":
+			if f.ReportedBy == "exporter" {
+				out = append(out, f)
+			}
+		case "mean":
+			if f.ReportedBy == "importer" {
+				scale := (r[0] + r[1]) / 2 / r[1]
+				f.Volume, f.Value = f.Volume*scale, f.Value*scale
+				out = append(out, f)
+			}
+		default:
+			if f.ReportedBy == "importer" {
+				out = append(out, f)
+			}
+		}
+	}
+	return out
+}
+
+// mirrorRecord compares the two reports of one bilateral flow. When only one
+// side reports, Estimated names the side taken from the partner's RegionStats.
+type mirrorRecord struct {
+	Source           string  `json:"source"`
+	Target           string  `json:"target"`
+	ExporterReported float64 `json:"exporterReported"`
+	ImporterReported float64 `json:"importerReported"`
+	Estimated        string  `json:"estimated,omitempty"`
+	Gap              float64 `json:"gap"`
+	Asymmetry        float64 `json:"asymmetry"`
+	Flagged          bool    `json:"flagged"`
+}
+
+// tradeReconciliation is the per-region comparison of reported totals with
+// the bilateral flows. Gaps are reported − flows: positive means part of the
+// reported trade has no bilateral flow, negative that flows exceed it.
+type tradeReconciliation struct {
+	RegionID       string   `json:"regionId"`
+	RegionName     string   `json:"regionName"`
+	ReportedExport *float64 `json:"reportedExport"`
+	FlowExport     float64  `json:"flowExport"`
+	ExportGap      *float64 `json:"exportGap"`
+	ExportCoverage *float64 `json:"exportCoverage"`
+	ExportStatus   string   `json:"exportStatus"`
+	ReportedImport *float64 `json:"reportedImport"`
+	FlowImport     float64  `json:"flowImport"`
+	ImportGap      *float64 `json:"importGap"`
+	ImportCoverage *float64 `json:"importCoverage"`
+	ImportStatus   string   `json:"importStatus"`
+}
+
+type resourceReconciliation struct {
+	ResourceID     string                `json:"resourceId"`
+	Year           int                   `json:"year"`
+	Unit           string                `json:"unit"`
+	ReportedExport float64               `json:"reportedExport"`
+	ReportedImport float64               `json:"reportedImport"`
+	FlowTotal      float64               `json:"flowTotal"`
+	Regions        []tradeReconciliation `json:"regions"`
+	Mirrors        []mirrorRecord        `json:"mirrors"`
+	Flagged        int                   `json:"flagged"`
+	RestOfWorld    []ResourceFlow        `json:"restOfWorld,omitempty"`
+}
+
+// reconcileTrade checks RegionStats export and import totals against the
+// bilateral flows of each resource and year.
+func reconcileTrade(args map[string]any) (any, error) {
+	resourceID, _ := args["resource_id"].(string)
+	year := toInt(args["year"])
+	tolerance := defaultReconcileTolerance
+	if v, ok := args["tolerance"].(float64); ok {
+		if v < 0 {
+			return nil, fmt.Errorf("tolerance must not be negative")
+		}
+		tolerance = v
+	}
+	preference, _ := args["mirror_preference"].(string)
+	if preference == "" {
+		preference = defaultMirrorPreference
+	}
+	if !containsString(mirrorPreferences, preference) {
+		return nil, fmt.Errorf("mirror_preference must be one of %v", mirrorPreferences)
+	}
+	includeRow, _ := args["include_rest_of_world"].(bool)
+
+	ids := sortedStatKeys()
+	if resourceID != "" {
+		if resourceByID(resourceID) == nil {
+			return nil, fmt.Errorf("resource not found: %s", resourceID)
+		}
+		ids = []string{resourceID}
+	}
+	out := make([]resourceReconciliation, 0, len(ids))
+	flagged := 0
+	for _, id := range ids {
+		y := year
+		if y == 0 {
+			y = latestDataYear(id)
+		}
+		r := reconcileResource(id, y, tolerance, preference, includeRow)
+		flagged += r.Flagged
+		out = append(out, r)
+	}
+	return map[string]any{
+		"resources":         out,
+		"count":             len(out),
+		"flagged":           flagged,
+		"tolerance":         tolerance,
+		"mirror_preference": preference,
+	}, nil
+}
+
+func reconcileResource(resourceID string, year int, tolerance float64, preference string, includeRow bool) resourceReconciliation {
+	r := resourceReconciliation{ResourceID: resourceID, Year: year, Regions: []tradeReconciliation{}, Mirrors: []mirrorRecord{}}
+	if res := resourceByID(resourceID); res != nil {
+		r.Unit = res.Unit
+	}
+
+	stats := map[string]RegionStats{}
+	for _, s := range resourceStats[resourceID] {
+		if s.Year == year {
+			stats[s.RegionID] = s
+			r.ReportedExport += s.Export
+			r.ReportedImport += s.Import
+		}
+	}
+	r.ReportedExport, r.ReportedImport = round6(r.ReportedExport), round6(r.ReportedImport)
+
+	// Sum each pair's records by reporter to compare the two sides.
+	type reports struct{ exporter, importer, single float64 }
+	pairs := map[[2]string]*reports{}
+	for _, f := range flows {
+		if f.ResourceID != resourceID || f.Year != year || f.SourceRegion == f.TargetRegion {
+			continue
+		}
+		key := [2]string{f.SourceRegion, f.TargetRegion}
+		if pairs[key] == nil {
+			pairs[key] = &reports{}
+		}
+		switch f.ReportedBy {
+		case "exporter":
+			pairs[key].exporter += f.Volume
+		case "importer":
+			pairs[key].importer += f.Volume
+		default:
+			pairs[key].single += f.Volume
+		}
+	}
+	keys := make([][2]string, 0, len(pairs))
+	for k := range pairs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, k := range keys {
+		p := pairs[k]
+		switch {
+		case p.exporter > 0 && p.importer > 0:
+			r.Mirrors = append(r.Mirrors, mirrorOf(k, p.exporter, p.importer, "", tolerance))
+		case p.importer > 0 && p.single == 0:
+			if v, ok := mirrorEstimate(stats, k[1], k[0], false); ok {
+				r.Mirrors = append(r.Mirrors, mirrorOf(k, v, p.importer, "exporter", tolerance))
+			}
+		default:
+			// Flows without a reporter read as the exporter's record.
+			if v, ok := mirrorEstimate(stats, k[0], k[1], true); ok {
+				r.Mirrors = append(r.Mirrors, mirrorOf(k, p.single+p.exporter+p.importer, v, "importer", tolerance))
+			}
+		}
+	}
+
+	// Totals use the flows with mirror pairs settled by the preference.
+	flowExport, flowImport := map[string]float64{}, map[string]float64{}
+	for _, f := range settleMirrors(flows, preference) {
+		if f.ResourceID != resourceID || f.Year != year || f.SourceRegion == f.TargetRegion {
+			continue
+		}
+		flowExport[f.SourceRegion] += f.Volume
+		flowImport[f.TargetRegion] += f.Volume
+		r.FlowTotal += f.Volume
+	}
+	r.FlowTotal = round6(r.FlowTotal)
+	for _, m := range r.Mirrors {
+		if m.Flagged {
+			r.Flagged++
+		}
+	}
+
+	regions := map[string]bool{}
+	for _, m := range []map[string]float64{flowExport, flowImport} {
+		for id := range m {
+			regions[id] = true
+		}
+	}
+	for id := range stats {
+		regions[id] = true
+	}
+	for _, id := range sortedKeys(regions) {
+		tr := tradeReconciliation{RegionID: id, RegionName: regionName(id), FlowExport: round6(flowExport[id]), FlowImport: round6(flowImport[id]), ExportStatus: "no_stats", ImportStatus: "no_stats"}
+		if s, ok := stats[id]; ok {
+			tr.ReportedExport, tr.ExportGap, tr.ExportCoverage, tr.ExportStatus = compareTrade(s.Export, flowExport[id], tolerance)
+			tr.ReportedImport, tr.ImportGap, tr.ImportCoverage, tr.ImportStatus = compareTrade(s.Import, flowImport[id], tolerance)
+			if tr.ExportStatus != "ok" {
+				r.Flagged++
+			}
+			if tr.ImportStatus != "ok" {
+				r.Flagged++
+			}
+			if includeRow {
+				if tr.ExportStatus == "under_allocated" {
+					r.RestOfWorld = append(r.RestOfWorld, restOfWorldFlow(resourceID, year, id, restOfWorld, *tr.ExportGap))
+				}
+				if tr.ImportStatus == "under_allocated" {
+					r.RestOfWorld = append(r.RestOfWorld, restOfWorldFlow(resourceID, year, restOfWorld, id, *tr.ImportGap))
+				}
+			}
+		}
+		r.Regions = append(r.Regions, tr)
+	}
+	if includeRow && r.RestOfWorld == nil {
+		r.RestOfWorld = []ResourceFlow{}
+	}
+	return r
+}
+
+func mirrorOf(pair [2]string, exporter, importer float64, estimated string, tolerance float64) mirrorRecord {
+	m := mirrorRecord{Source: pair[0], Target: pair[1], ExporterReported: round6(exporter), ImporterReported: round6(importer), Estimated: estimated, Gap: round6(exporter - importer)}
+	if top := math.Max(exporter, importer); top > 0 {
+		m.Asymmetry = round6(math.Abs(exporter-importer) / top)
+	}
+	m.Flagged = m.Asymmetry > tolerance
+	return m
+}
+
+// mirrorEstimate is the partner's side of a flow reported by one side only:
+// the partner's reported imports (or exports) split over the other regions in
+// proportion to their reported exports (or imports). For a flow A→B reported
+// by A, it is B's imports × A's share of the exports of all regions but B.
+func mirrorEstimate(stats map[string]RegionStats, reporter, partner string, reporterExports bool) (float64, bool) {
+	p, okP := stats[partner]
+	s, okS := stats[reporter]
+	if !okP || !okS {
+		return 0, false
+	}
+	total, own, partnerTotal := 0.0, s.Import, p.Export
+	if reporterExports {
+		own, partnerTotal = s.Export, p.Import
+	}
+	for id, o := range stats {
+		if id == partner {
+			continue
+		}
+		if reporterExports {
+			total += o.Export
+		} else {
+			total += o.Import
+		}
+	}
+	if total <= 0 {
+		return 0, false
+	}
+	return partnerTotal * own / total, true
+}
+
+// compareTrade classifies a reported total against its bilateral flows.
+func compareTrade(reported, flowed, tolerance float64) (*float64, *float64, *float64, string) {
+	rep, gap := round6(reported), round6(reported-flowed)
+	status := "ok"
+	switch {
+	case gap > tolerance*reported:
+		status = "under_allocated"
+	case -gap > tolerance*reported:
+		status = "over_allocated"
+	}
+	if reported <= 0 {
+		return &rep, &gap, nil, status
+	}
+	coverage := round6(flowed / reported)
+	return &rep, &gap, &coverage, status
+}
+
+// restOfWorldFlow books an unallocated residual against the "row" region.
+func restOfWorldFlow(resourceID string, year int, source, target string, volume float64) ResourceFlow {
+	return ResourceFlow{
+		ID:         fmt.Sprintf("%s-%s-%s-%s-%d", restOfWorld, resourceID, source, target, year),
+		ResourceID: resourceID, SourceRegion: source, TargetRegion: target, Year: year, Volume: volume,
+		Inferred: true, Method: "residual",
+	}
+}
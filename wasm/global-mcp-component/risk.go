@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Risk scores are weighted means of components in [0, 1], scaled to 0–100.
+// Components that do not apply (no reserves reported) drop out and the
+// remaining weights are renormalized.
+var (
+	resourceRiskWeights = map[string]float64{"concentration": 0.5, "dependency": 0.3, "depletion": 0.2}
+	regionRiskWeights   = map[string]float64{"dependency": 0.5, "supplier_concentration": 0.3, "production_concentration": 0.2}
+)
+
+// depletionHorizon is the reserve-to-production span (years) below which
+// depletion starts to count; at 0 years the component is 1.
+const depletionHorizon = 50.0
+
+const (
+	resourceRiskFormula = "100 × Σ wᵢ·cᵢ / Σ wᵢ; concentration = HHI/10000, dependency = consumption-weighted mean of max(0, net import dependency) capped at 1, depletion = max(0, 1 − R/P ÷ 50)"
+	regionRiskFormula   = "100 × Σ wᵢ·cᵢ / Σ wᵢ; dependency = clamp((import − export) / consumption, 0, 1), supplier_concentration = HHI of import sources/10000, production_concentration = global production HHI/10000"
+)
+
+type productionShare struct {
+	RegionID   string  `json:"regionId"`
+	Production float64 `json:"production"`
+	Share      float64 `json:"share"`
+}
+
+type regionRisk struct {
+	RegionID            string             `json:"regionId"`
+	RegionName          string             `json:"regionName"`
+	Import              float64            `json:"import"`
+	Export              float64            `json:"export"`
+	Consumption         float64            `json:"consumption"`
+	NetImportDependency *float64           `json:"netImportDependency"`
+	SupplierHHI         float64            `json:"supplierHhi"`
+	SupplierBasis       string             `json:"supplierBasis"`
+	Components          map[string]float64 `json:"components"`
+	Score               float64            `json:"score"`
+}
+
+type resourceRisk struct {
+	ResourceID          string             `json:"resourceId"`
+	Year                int                `json:"year"`
+	Unit                string             `json:"unit"`
+	TotalProduction     float64            `json:"totalProduction"`
+	TotalReserve        float64            `json:"totalReserve"`
+	ProductionShares    []productionShare  `json:"productionShares"`
+	HHI                 float64            `json:"hhi"`
+	Concentration       string             `json:"concentration"`
+	ReserveToProduction *float64           `json:"reserveToProduction"`
+	Components          map[string]float64 `json:"components"`
+	Score               float64            `json:"score"`
+	Regions             []regionRisk       `json:"regions"`
+	ImportingRegions    []string           `json:"importingRegions"`
+}
+
+// hhiClass follows the US merger guideline thresholds on the 0–10000 scale.
+func hhiClass(hhi float64) string {
+	switch {
+	case hhi > 2500:
+		return "high"
+	case hhi >= 1500:
+		return "moderate"
+	}
+	return "low"
+}
+
+func weightedScore(components, weights map[string]float64) float64 {
+	keys := make([]string, 0, len(components))
+	for k := range components {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sum, wsum := 0.0, 0.0
+	for _, k := range keys {
+		sum += weights[k] * components[k]
+		wsum += weights[k]
+	}
+	if wsum == 0 {
+		return 0
+	}
+	return round6(100 * sum / wsum)
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// supplyRisk computes concentration, dependency and depletion indices for
+// one resource or every resource with stats.
+func supplyRisk(args map[string]any) (any, error) {
+	resourceID, _ := args["resource_id"].(string)
+	year := toInt(args["year"])
+	ids := sortedStatKeys()
+	if resourceID != "" {
+		if _, ok := resourceStats[resourceID]; !ok {
+			return nil, fmt.Errorf("no stats for resource: %s", resourceID)
+		}
+		ids = []string{resourceID}
+	}
+	out := make([]resourceRisk, 0, len(ids))
+	for _, id := range ids {
+		y := year
+		if y == 0 {
+			y = latestStatsYear(id)
+		}
+		r, ok := resourceRiskFor(id, y, resourceStats[id], settledFlows())
+		if !ok {
+			if resourceID != "" {
+				return nil, fmt.Errorf("no stats for %s in %d", id, y)
+			}
+			continue
+		}
+		out = append(out, r)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return map[string]any{
+		"resources": out,
+		"count":     len(out),
+		"weights":   map[string]any{"resource": resourceRiskWeights, "region": regionRiskWeights},
+		"formulas":  map[string]string{"resource": resourceRiskFormula, "region": regionRiskFormula},
+		"thresholds": map[string]any{
+			"hhi":               map[string]float64{"moderate": 1500, "high": 2500},
+			"depletion_horizon": depletionHorizon,
+		},
+	}, nil
+}
+
+// resourceRiskFor scores one resource from the given stats and flows, so
+// scenarios can be scored on modified data.
+func resourceRiskFor(resourceID string, year int, allStats []RegionStats, flowList []ResourceFlow) (resourceRisk, bool) {
+	stats := make([]RegionStats, 0)
+	for _, s := range allStats {
+		if s.Year == year {
+			stats = append(stats, s)
+		}
+	}
+	if len(stats) == 0 {
+		return resourceRisk{}, false
+	}
+	r := resourceRisk{ResourceID: resourceID, Year: year, Components: map[string]float64{}, ImportingRegions: []string{}}
+	if res := resourceByID(resourceID); res != nil {
+		r.Unit = res.Unit
+	}
+	for _, s := range stats {
+		r.TotalProduction += s.Production
+		r.TotalReserve += s.Reserve
+	}
+	for _, s := range stats {
+		share := 0.0
+		if r.TotalProduction > 0 {
+			share = s.Production / r.TotalProduction
+		}
+		r.ProductionShares = append(r.ProductionShares, productionShare{RegionID: s.RegionID, Production: s.Production, Share: round6(share)})
+		r.HHI += (share * 100) * (share * 100)
+	}
+	sort.SliceStable(r.ProductionShares, func(i, j int) bool { return r.ProductionShares[i].Share > r.ProductionShares[j].Share })
+	r.HHI = round6(r.HHI)
+	r.Concentration = hhiClass(r.HHI)
+	r.Components["concentration"] = round6(r.HHI / 10000)
+
+	// Reserves and production are taken in the units RegionStats reports;
+	// the ratio reads as years when reserves are in annual production units.
+	if r.TotalReserve > 0 && r.TotalProduction > 0 {
+		rp := round6(r.TotalReserve / r.TotalProduction)
+		r.ReserveToProduction = &rp
+		r.Components["depletion"] = round6(clamp01(1 - rp/depletionHorizon))
+	}
+
+	dependencySum, consumptionSum := 0.0, 0.0
+	for _, s := range stats {
+		rr := regionRiskFor(resourceID, year, s, flowList, r.ProductionShares, r.Components["concentration"])
+		r.Regions = append(r.Regions, rr)
+		if rr.NetImportDependency != nil && *rr.NetImportDependency > 0 {
+			r.ImportingRegions = append(r.ImportingRegions, s.RegionID)
+		}
+		if rr.NetImportDependency != nil {
+			dependencySum += clamp01(*rr.NetImportDependency) * s.Consumption
+			consumptionSum += s.Consumption
+		}
+	}
+	if consumptionSum > 0 {
+		r.Components["dependency"] = round6(dependencySum / consumptionSum)
+	} else {
+		r.Components["dependency"] = 0
+	}
+	sort.SliceStable(r.Regions, func(i, j int) bool { return r.Regions[i].Score > r.Regions[j].Score })
+	r.Score = weightedScore(r.Components, resourceRiskWeights)
+	return r, true
+}
+
+// regionRiskFor scores one region. Supplier concentration comes from recorded
+// inbound flows; without any, imports are assumed to follow world production
+// shares of the other producers.
+func regionRiskFor(resourceID string, year int, s RegionStats, flowList []ResourceFlow, shares []productionShare, productionConcentration float64) regionRisk {
+	rr := regionRisk{
+		RegionID: s.RegionID, RegionName: s.RegionName,
+		Import: s.Import, Export: s.Export, Consumption: s.Consumption,
+		Components: map[string]float64{"production_concentration": productionConcentration},
+	}
+	if s.Consumption > 0 {
+		nid := round6((s.Import - s.Export) / s.Consumption)
+		rr.NetImportDependency = &nid
+		rr.Components["dependency"] = round6(clamp01(nid))
+	}
+
+	inbound := map[string]float64{}
+	total := 0.0
+	for _, f := range flowList {
+		if f.ResourceID == resourceID && f.Year == year && f.TargetRegion == s.RegionID && f.SourceRegion != s.RegionID {
+			inbound[f.SourceRegion] += f.Volume
+			total += f.Volume
+		}
+	}
+	rr.SupplierBasis = "flows"
+	if total == 0 {
+		rr.SupplierBasis = "production_shares"
+		for _, p := range shares {
+			if p.RegionID != s.RegionID {
+				inbound[p.RegionID] += p.Production
+				total += p.Production
+			}
+		}
+	}
+	if total > 0 {
+		for _, v := range inbound {
+			share := v / total * 100
+			rr.SupplierHHI += share * share
+		}
+	}
+	rr.SupplierHHI = round6(rr.SupplierHHI)
+	if s.Import > 0 {
+		rr.Components["supplier_concentration"] = round6(rr.SupplierHHI / 10000)
+	}
+	rr.Score = weightedScore(rr.Components, regionRiskWeights)
+	return rr
+}
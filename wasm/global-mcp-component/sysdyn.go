@@ -0,0 +1,449 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	maxSimulationSteps  = 10000
+	defaultSimDelay     = 1.0
+	defaultSimHorizon   = 50.0
+	defaultSimTimeStep  = 1.0
+	firstOrderDelayType = "first_order"
+	pipelineDelayType   = "pipeline"
+)
+
+// systemParameters is a runnable parameterization of a SystemModel. Stock
+// equations give the net rate of change; flow and auxiliary equations give
+// the value itself. Delays are keyed "source->target".
+type systemParameters struct {
+	Equations  map[string]string  `json:"equations"`
+	Initial    map[string]float64 `json:"initial"`
+	Parameters map[string]float64 `json:"parameters"`
+	Delays     map[string]float64 `json:"delays"`
+	TEnd       float64            `json:"tEnd"`
+	DT         float64            `json:"dt"`
+}
+
+// systemDefaults ship with the built-in models so they run without input.
+// In global-energy-balance demand grows exponentially, price rises with the
+// demand/production ratio, and production answers the price three years late.
+var systemDefaults = map[string]systemParameters{
+	"global-energy-balance": {
+		Equations: map[string]string{
+			"demand":     "growth_rate * demand",
+			"production": "capacity * pow(price / base_price, supply_elasticity)",
+			"price":      "base_price * pow(demand / production, price_elasticity)",
+		},
+		Initial:    map[string]float64{"demand": 100, "price": 80},
+		Parameters: map[string]float64{"growth_rate": 0.02, "capacity": 100, "base_price": 80, "supply_elasticity": 0.8, "price_elasticity": 1.5},
+		Delays:     map[string]float64{"price->production": 3},
+		TEnd:       50,
+		DT:         0.25,
+	},
+}
+
+type simDelay struct {
+	Source string  `json:"source"`
+	Target string  `json:"target"`
+	Time   float64 `json:"time"`
+	state  int
+}
+
+// simModel is a SystemModel compiled for integration.
+type simModel struct {
+	sys        SystemModel
+	equations  map[string]*expr
+	params     map[string]float64
+	initial    map[string]float64
+	stocks     []string
+	algebraic  []string // flows and auxiliaries in evaluation order
+	delays     map[[2]string]*simDelay
+	delayType  string
+	stateSize  int
+	stockIndex map[string]int
+	history    map[string][][2]float64
+	tStart     float64
+}
+
+func delayKey(source, target string) string { return source + "->" + target }
+
+// simulateSystem integrates a system model over [t_start, t_end] and
+// returns one time series per node.
+func simulateSystem(args map[string]any) (any, error) {
+	systemID, _ := args["system_id"].(string)
+	var sys *SystemModel
+	for i := range systems {
+		if systems[i].ID == systemID {
+			sys = &systems[i]
+		}
+	}
+	if sys == nil {
+		return nil, fmt.Errorf("system not found: %s", systemID)
+	}
+	p := mergeSystemParameters(systemDefaults[systemID], args)
+
+	method, _ := args["method"].(string)
+	if method == "" {
+		method = "rk4"
+	}
+	if method != "rk4" && method != "euler" {
+		return nil, fmt.Errorf("method must be euler or rk4")
+	}
+	delayType, _ := args["delay_type"].(string)
+	if delayType == "" {
+		delayType = firstOrderDelayType
+	}
+	if delayType != firstOrderDelayType && delayType != pipelineDelayType {
+		return nil, fmt.Errorf("delay_type must be %s or %s", firstOrderDelayType, pipelineDelayType)
+	}
+	tStart, _ := args["t_start"].(float64)
+	tEnd, dt := p.TEnd, p.DT
+	if v, ok := args["t_end"].(float64); ok {
+		tEnd = v
+	}
+	if v, ok := args["dt"].(float64); ok {
+		dt = v
+	}
+	if dt <= 0 || tEnd <= tStart {
+		return nil, fmt.Errorf("need dt > 0 and t_end > t_start")
+	}
+	steps := int(math.Round((tEnd - tStart) / dt))
+	if steps > maxSimulationSteps {
+		return nil, fmt.Errorf("%d steps exceed the limit of %d; increase dt or shorten the horizon", steps, maxSimulationSteps)
+	}
+	defaultDelay := defaultSimDelay
+	if v, ok := args["default_delay"].(float64); ok {
+		defaultDelay = v
+	}
+
+	m, warnings, err := compileSystem(*sys, p, delayType, defaultDelay)
+	if err != nil {
+		return nil, err
+	}
+	m.tStart = tStart
+	for _, d := range m.delays {
+		if d.Time < dt {
+			warnings = append(warnings, fmt.Sprintf("delay %s->%s (%g) is shorter than dt (%g)", d.Source, d.Target, d.Time, dt))
+		}
+	}
+	sort.Strings(warnings)
+
+	y := make([]float64, m.stateSize)
+	for i, id := range m.stocks {
+		y[i] = m.initial[id]
+	}
+	for _, d := range m.delays {
+		if d.state >= 0 {
+			y[d.state] = m.initial[d.Source]
+		}
+	}
+	times := make([]float64, 0, steps+1)
+	series := map[string][]float64{}
+	for k := 0; k <= steps; k++ {
+		t := tStart + float64(k)*dt
+		vals, _ := m.derivatives(t, y)
+		for _, n := range sys.Nodes {
+			if math.IsNaN(vals[n.ID]) || math.IsInf(vals[n.ID], 0) {
+				return nil, fmt.Errorf("simulation diverged at t=%g: %s is %v", t, n.ID, vals[n.ID])
+			}
+			m.history[n.ID] = append(m.history[n.ID], [2]float64{t, vals[n.ID]})
+			series[n.ID] = append(series[n.ID], round6(vals[n.ID]))
+		}
+		times = append(times, round6(t))
+		if k == steps {
+			break
+		}
+		if method == "euler" {
+			_, d := m.derivatives(t, y)
+			for i := range y {
+				y[i] += dt * d[i]
+			}
+			continue
+		}
+		_, k1 := m.derivatives(t, y)
+		_, k2 := m.derivatives(t+dt/2, addScaled(y, k1, dt/2))
+		_, k3 := m.derivatives(t+dt/2, addScaled(y, k2, dt/2))
+		_, k4 := m.derivatives(t+dt, addScaled(y, k3, dt))
+		for i := range y {
+			y[i] += dt / 6 * (k1[i] + 2*k2[i] + 2*k3[i] + k4[i])
+		}
+	}
+
+	final := map[string]float64{}
+	for id, s := range series {
+		final[id] = s[len(s)-1]
+	}
+	delays := make([]simDelay, 0, len(m.delays))
+	for _, d := range m.delays {
+		delays = append(delays, *d)
+	}
+	sort.Slice(delays, func(i, j int) bool {
+		return delayKey(delays[i].Source, delays[i].Target) < delayKey(delays[j].Source, delays[j].Target)
+	})
+	return map[string]any{
+		"system_id":  systemID,
+		"method":     method,
+		"delay_type": delayType,
+		"t_start":    tStart,
+		"t_end":      tEnd,
+		"dt":         dt,
+		"steps":      steps,
+		"equations":  p.Equations,
+		"initial":    p.Initial,
+		"parameters": p.Parameters,
+		"delays":     delays,
+		"times":      times,
+		"series":     series,
+		"final":      final,
+		"warnings":   warnings,
+	}, nil
+}
+
+// mergeSystemParameters overlays the equations, initial values, parameters
+// and delays given in args on the model defaults.
+func mergeSystemParameters(defaults systemParameters, args map[string]any) systemParameters {
+	p := systemParameters{
+		Equations: map[string]string{}, Initial: map[string]float64{}, Parameters: map[string]float64{}, Delays: map[string]float64{},
+		TEnd: defaults.TEnd, DT: defaults.DT,
+	}
+	if p.TEnd == 0 {
+		p.TEnd = defaultSimHorizon
+	}
+	if p.DT == 0 {
+		p.DT = defaultSimTimeStep
+	}
+	for k, v := range defaults.Equations {
+		p.Equations[k] = v
+	}
+	if m, ok := args["equations"].(map[string]any); ok {
+		for k, v := range m {
+			if s, ok := v.(string); ok {
+				p.Equations[k] = s
+			}
+		}
+	}
+	for _, pair := range []struct {
+		dst      map[string]float64
+		defaults map[string]float64
+		arg      string
+	}{
+		{p.Initial, defaults.Initial, "initial"},
+		{p.Parameters, defaults.Parameters, "parameters"},
+		{p.Delays, defaults.Delays, "delays"},
+	} {
+		for k, v := range pair.defaults {
+			pair.dst[k] = v
+		}
+		if m, ok := args[pair.arg].(map[string]any); ok {
+			for k, v := range m {
+				if f, ok := v.(float64); ok {
+					pair.dst[k] = f
+				}
+			}
+		}
+	}
+	return p
+}
+
+// compileSystem parses the equations, checks every name they read, wires the
+// Delay edges and orders flows and auxiliaries so each is computed after the
+// values it depends on. References without a matching edge are warnings.
+func compileSystem(sys SystemModel, p systemParameters, delayType string, defaultDelay float64) (*simModel, []string, error) {
+	m := &simModel{
+		sys: sys, equations: map[string]*expr{}, params: p.Parameters, initial: p.Initial,
+		delays: map[[2]string]*simDelay{}, delayType: delayType, stockIndex: map[string]int{}, history: map[string][][2]float64{},
+	}
+	warnings := make([]string, 0)
+	nodes := map[string]SystemNode{}
+	for _, n := range sys.Nodes {
+		nodes[n.ID] = n
+		if _, ok := p.Parameters[n.ID]; ok {
+			return nil, nil, fmt.Errorf("parameter %s has the same name as a node", n.ID)
+		}
+	}
+	for id := range p.Equations {
+		if _, ok := nodes[id]; !ok {
+			return nil, nil, fmt.Errorf("equation for unknown node: %s", id)
+		}
+	}
+	missing := make([]string, 0)
+	for _, n := range sys.Nodes {
+		src, ok := p.Equations[n.ID]
+		if !ok {
+			missing = append(missing, n.ID)
+			continue
+		}
+		e, err := parseExpr(src)
+		if err != nil {
+			return nil, nil, fmt.Errorf("equation for %s: %v", n.ID, err)
+		}
+		m.equations[n.ID] = e
+		if n.Level == "stock" {
+			if _, ok := p.Initial[n.ID]; !ok {
+				return nil, nil, fmt.Errorf("stock %s needs an initial value", n.ID)
+			}
+			m.stockIndex[n.ID] = len(m.stocks)
+			m.stocks = append(m.stocks, n.ID)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("missing equations for: %s", strings.Join(missing, ", "))
+	}
+	m.stateSize = len(m.stocks)
+
+	edges := map[[2]string]SystemEdge{}
+	for _, e := range sys.Edges {
+		edges[[2]string{e.Source, e.Target}] = e
+		if !e.Delay {
+			continue
+		}
+		d := &simDelay{Source: e.Source, Target: e.Target, Time: defaultDelay, state: -1}
+		if v, ok := p.Delays[delayKey(e.Source, e.Target)]; ok {
+			d.Time = v
+		}
+		if d.Time <= 0 {
+			return nil, nil, fmt.Errorf("delay %s must be positive", delayKey(e.Source, e.Target))
+		}
+		if _, ok := p.Initial[e.Source]; !ok && nodes[e.Source].Level != "stock" {
+			return nil, nil, fmt.Errorf("delayed source %s needs an initial value", e.Source)
+		}
+		if delayType == firstOrderDelayType {
+			d.state = m.stateSize
+			m.stateSize++
+		}
+		m.delays[[2]string{e.Source, e.Target}] = d
+	}
+	for key := range p.Delays {
+		parts := strings.SplitN(key, "->", 2)
+		if len(parts) != 2 || m.delays[[2]string{parts[0], parts[1]}] == nil {
+			warnings = append(warnings, fmt.Sprintf("delay %s does not match a Delay edge", key))
+		}
+	}
+
+	// Dependencies between algebraic nodes; delayed reads do not count.
+	deps := map[string][]string{}
+	for _, n := range sys.Nodes {
+		for _, name := range m.equations[n.ID].names() {
+			if _, ok := p.Parameters[name]; ok || name == "time" {
+				continue
+			}
+			if _, ok := nodes[name]; !ok {
+				return nil, nil, fmt.Errorf("equation for %s reads unknown name %s", n.ID, name)
+			}
+			if name != n.ID || n.Level != "stock" {
+				if _, ok := edges[[2]string{name, n.ID}]; !ok {
+					warnings = append(warnings, fmt.Sprintf("equation for %s reads %s without an edge %s->%s", n.ID, name, name, n.ID))
+				}
+			}
+			if n.Level != "stock" && nodes[name].Level != "stock" && m.delays[[2]string{name, n.ID}] == nil {
+				deps[n.ID] = append(deps[n.ID], name)
+			}
+		}
+	}
+	for _, e := range sys.Edges {
+		if eq := m.equations[e.Target]; eq != nil && !containsString(eq.names(), e.Source) {
+			warnings = append(warnings, fmt.Sprintf("edge %s->%s is not used by the equation for %s", e.Source, e.Target, e.Target))
+		}
+	}
+
+	done := map[string]bool{}
+	for len(m.algebraic) < len(sys.Nodes)-len(m.stocks) {
+		progressed := false
+		for _, n := range sys.Nodes {
+			if n.Level == "stock" || done[n.ID] {
+				continue
+			}
+			ready := true
+			for _, d := range deps[n.ID] {
+				if !done[d] {
+					ready = false
+				}
+			}
+			if ready {
+				done[n.ID] = true
+				m.algebraic = append(m.algebraic, n.ID)
+				progressed = true
+			}
+		}
+		if !progressed {
+			loop := make([]string, 0)
+			for _, n := range sys.Nodes {
+				if n.Level != "stock" && !done[n.ID] {
+					loop = append(loop, n.ID)
+				}
+			}
+			return nil, nil, fmt.Errorf("algebraic loop without a stock or delay among: %s", strings.Join(loop, ", "))
+		}
+	}
+	return m, warnings, nil
+}
+
+// derivatives evaluates every node at (t, y) and returns the node values and
+// the rates of change of the state: stocks, then first-order delay levels.
+func (m *simModel) derivatives(t float64, y []float64) (map[string]float64, []float64) {
+	vals := map[string]float64{}
+	for i, id := range m.stocks {
+		vals[id] = y[i]
+	}
+	lookup := func(target string) func(string) float64 {
+		return func(name string) float64 {
+			if name == "time" {
+				return t
+			}
+			if v, ok := m.params[name]; ok {
+				return v
+			}
+			if d := m.delays[[2]string{name, target}]; d != nil {
+				if d.state >= 0 {
+					return y[d.state]
+				}
+				return m.delayed(name, t-d.Time)
+			}
+			return vals[name]
+		}
+	}
+	for _, id := range m.algebraic {
+		vals[id] = m.equations[id].eval(lookup(id))
+	}
+	dy := make([]float64, m.stateSize)
+	for i, id := range m.stocks {
+		dy[i] = m.equations[id].eval(lookup(id))
+	}
+	for _, d := range m.delays {
+		if d.state >= 0 {
+			dy[d.state] = (vals[d.Source] - y[d.state]) / d.Time
+		}
+	}
+	return vals, dy
+}
+
+// delayed returns a node's recorded value at time t, interpolating between
+// steps. Before the start it is the initial value; past the last recorded
+// step (delays shorter than dt) it is the latest value.
+func (m *simModel) delayed(id string, t float64) float64 {
+	h := m.history[id]
+	if t <= m.tStart || len(h) == 0 {
+		return m.initial[id]
+	}
+	i := sort.Search(len(h), func(i int) bool { return h[i][0] >= t })
+	if i == len(h) {
+		return h[len(h)-1][1]
+	}
+	if i == 0 || h[i][0] == t {
+		return h[i][1]
+	}
+	a, b := h[i-1], h[i]
+	return a[1] + (b[1]-a[1])*(t-a[0])/(b[0]-a[0])
+}
+
+func addScaled(y, d []float64, h float64) []float64 {
+	out := make([]float64, len(y))
+	for i := range y {
+		out[i] = y[i] + h*d[i]
+	}
+	return out
+}
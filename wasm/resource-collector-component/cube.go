@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ---------- RDF Data Cube vocabulary ----------
+
+// The export follows the W3C RDF Data Cube vocabulary (qb:) with the SDMX-RDF
+// content-oriented dimensions, measures and attributes, QUDT units, and
+// schema.org Dataset metadata on the qb:DataSet node.
+const (
+	qbNS            = "http://purl.org/linked-data/cube#"
+	sdmxDimensionNS = "http://purl.org/linked-data/sdmx/2009/dimension#"
+	sdmxMeasureNS   = "http://purl.org/linked-data/sdmx/2009/measure#"
+	sdmxAttributeNS = "http://purl.org/linked-data/sdmx/2009/attribute#"
+	schemaNS        = "https://schema.org/"
+	skosNS          = "http://www.w3.org/2004/02/skos/core#"
+	rdfsNS          = "http://www.w3.org/2000/01/rdf-schema#"
+	dctNS           = "http://purl.org/dc/terms/"
+	qudtNS          = "http://qudt.org/schema/qudt/"
+
+	resourcesBase = "https://resources.gftd.ai/"
+	datasetIRI    = resourcesBase + "content/resource/collection"
+	dsdIRI        = resourcesBase + "def/dsd/resource-collection"
+	gftdVocab     = resourcesBase + "def/"
+	countryBase   = "http://publications.europa.eu/resource/authority/country/"
+	yearBase      = "http://reference.data.gov.uk/id/year/"
+	qudtUnitBase  = "http://qudt.org/vocab/unit/"
+	worldBankIRI  = "https://data.worldbank.org/"
+)
+
+// cubeContext is the single top-level @context of every JSON-LD export.
+var cubeContext = map[string]any{
+	"qb":             qbNS,
+	"sdmx-dimension": sdmxDimensionNS,
+	"sdmx-measure":   sdmxMeasureNS,
+	"sdmx-attribute": sdmxAttributeNS,
+	"qudt":           qudtNS,
+	"unit":           qudtUnitBase,
+	"schema":         schemaNS,
+	"skos":           skosNS,
+	"rdfs":           rdfsNS,
+	"xsd":            xsdNS,
+	"dct":            dctNS,
+	"gftd":           gftdVocab,
+
+	"label":            "rdfs:label",
+	"dataSet":          map[string]any{"@id": "qb:dataSet", "@type": "@id"},
+	"structure":        map[string]any{"@id": "qb:structure", "@type": "@id"},
+	"component":        map[string]any{"@id": "qb:component", "@container": "@set"},
+	"dimension":        map[string]any{"@id": "qb:dimension", "@type": "@id"},
+	"measure":          map[string]any{"@id": "qb:measure", "@type": "@id"},
+	"attribute":        map[string]any{"@id": "qb:attribute", "@type": "@id"},
+	"order":            map[string]any{"@id": "qb:order", "@type": "xsd:integer"},
+	"refArea":          map[string]any{"@id": "sdmx-dimension:refArea", "@type": "@id"},
+	"refPeriod":        map[string]any{"@id": "sdmx-dimension:refPeriod", "@type": "@id"},
+	"resource":         map[string]any{"@id": "gftd:resource", "@type": "@id"},
+	"obsValue":         map[string]any{"@id": "sdmx-measure:obsValue", "@type": "xsd:decimal"},
+	"unitMeasure":      map[string]any{"@id": "sdmx-attribute:unitMeasure", "@type": "@id"},
+	"unitMult":         map[string]any{"@id": "sdmx-attribute:unitMult", "@type": "xsd:integer"},
+	"source":           map[string]any{"@id": "dct:source", "@type": "@id"},
+	"notation":         "skos:notation",
+	"resourceType":     "gftd:resourceType",
+	"dateCreated":      map[string]any{"@id": "schema:dateCreated", "@type": "xsd:dateTime"},
+	"name":             "schema:name",
+	"description":      "schema:description",
+	"isBasedOn":        map[string]any{"@id": "schema:isBasedOn", "@type": "@id"},
+	"temporalCoverage": "schema:temporalCoverage",
+	"spatialCoverage":  map[string]any{"@id": "schema:spatialCoverage", "@type": "@id", "@container": "@set"},
+	"about":            map[string]any{"@id": "schema:about", "@type": "@id", "@container": "@set"},
+}
+
+// qudtUnit is the QUDT unit of an observation with its SDMX unit multiplier
+// (power of ten) and a name for the SDMX unit code list.
+type qudtUnit struct {
+	Unit string
+	Mult int
+	Name string
+}
+
+// indicatorUnits derives units from World Bank indicator codes: ".ZS" series
+// (including ".ZS.UN") are percentage shares and ".XD" series are indices
+// with a base period of 100. The catalog's unit labels describe the resource,
+// not the indicator collected for it, so they are not used here.
+var indicatorUnits = []struct {
+	Marker string
+	Unit   qudtUnit
+}{
+	{".ZS", qudtUnit{Unit: "PERCENT", Mult: 0, Name: "percent"}},
+	{".XD", qudtUnit{Unit: "UNITLESS", Mult: 0, Name: "index"}},
+}
+
+func unitFor(indicator string) (qudtUnit, bool) {
+	for _, iu := range indicatorUnits {
+		if strings.HasSuffix(indicator, iu.Marker) || strings.Contains(indicator, iu.Marker+".") {
+			return iu.Unit, true
+		}
+	}
+	return qudtUnit{}, false
+}
+
+// resourceUnit is the unit of the indicator collected for a catalog resource.
+func resourceUnit(resourceID string) (qudtUnit, bool) {
+	for _, r := range catalog {
+		if r.ID == resourceID {
+			return unitFor(r.Indicator)
+		}
+	}
+	return qudtUnit{}, false
+}
+
+func resourceIRI(id string) string   { return resourcesBase + "content/resource/" + id }
+func regionIRI(code string) string   { return countryBase + strings.ToUpper(code) }
+func yearIRI(year int) string        { return yearBase + strconv.Itoa(year) }
+func unitIRI(u qudtUnit) string      { return qudtUnitBase + u.Unit }
+func formatDecimal(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+// observationIRI is also the path of the observation in the resources
+// repository: content/resource/{id}/{region}/{year}.
+func observationIRI(v collectedValue) string {
+	return fmt.Sprintf("%scontent/resource/%s/%s/%d", resourcesBase, v.ResourceID, strings.ToLower(v.Region), v.Year)
+}
+
+// cubeDataset describes the qb:DataSet an export is built from.
+type cubeDataset struct {
+	RunIDs      []string
+	DateCreated string
+	Values      []collectedValue
+}
+
+// buildCubeGraph renders values as RDF: the DataSet with its schema.org
+// metadata, the structure definition, the code list entries it uses and one
+// qb:Observation per value.
+func buildCubeGraph(ds cubeDataset) *rdfGraph {
+	resourcesByID := map[string]resourceDef{}
+	for _, r := range catalog {
+		resourcesByID[r.ID] = r
+	}
+	regionNames := map[string]string{}
+	resourceIDs := map[string]bool{}
+	minYear, maxYear := 0, 0
+	for _, v := range ds.Values {
+		regionNames[v.Region] = v.RegionName
+		resourceIDs[v.ResourceID] = true
+		if minYear == 0 || v.Year < minYear {
+			minYear = v.Year
+		}
+		if v.Year > maxYear {
+			maxYear = v.Year
+		}
+	}
+	regionCodes := sortedKeys(regionNames)
+	resourceList := sortedKeys(resourceIDs)
+
+	g := &rdfGraph{}
+	dataset := iriTerm(datasetIRI)
+	g.add(dataset, rdfType, iriTerm(qbNS+"DataSet"))
+	g.add(dataset, rdfType, iriTerm(schemaNS+"Dataset"))
+	g.add(dataset, qbNS+"structure", iriTerm(dsdIRI))
+	g.add(dataset, schemaNS+"name", literalTerm("GFTD Global Resource Collection", xsdString))
+	g.add(dataset, schemaNS+"description", literalTerm("Resource indicators per region and year collected from the World Bank API.", xsdString))
+	g.add(dataset, schemaNS+"isBasedOn", iriTerm(worldBankIRI))
+	for _, code := range regionCodes {
+		g.add(dataset, schemaNS+"spatialCoverage", iriTerm(regionIRI(code)))
+	}
+	for _, id := range resourceList {
+		g.add(dataset, schemaNS+"about", iriTerm(resourceIRI(id)))
+	}
+	if minYear > 0 {
+		g.add(dataset, schemaNS+"temporalCoverage", literalTerm(fmt.Sprintf("%d/%d", minYear, maxYear), xsdString))
+	}
+	if ds.DateCreated != "" {
+		g.add(dataset, schemaNS+"dateCreated", literalTerm(ds.DateCreated, xsdDateTime))
+	}
+	for _, id := range ds.RunIDs {
+		g.add(dataset, gftdVocab+"runId", literalTerm(id, xsdString))
+	}
+
+	dsd := iriTerm(dsdIRI)
+	g.add(dsd, rdfType, iriTerm(qbNS+"DataStructureDefinition"))
+	g.add(dsd, rdfsNS+"label", literalTerm("Resource indicator by resource, reference area and period", xsdString))
+	components := []struct {
+		kind, prop string
+		order      int
+	}{
+		{"dimension", gftdVocab + "resource", 1},
+		{"dimension", sdmxDimensionNS + "refArea", 2},
+		{"dimension", sdmxDimensionNS + "refPeriod", 3},
+		{"measure", sdmxMeasureNS + "obsValue", 0},
+		{"attribute", sdmxAttributeNS + "unitMeasure", 0},
+		{"attribute", sdmxAttributeNS + "unitMult", 0},
+	}
+	for i, c := range components {
+		spec := blankTerm(fmt.Sprintf("component%d", i+1))
+		g.add(dsd, qbNS+"component", spec)
+		g.add(spec, rdfType, iriTerm(qbNS+"ComponentSpecification"))
+		g.add(spec, qbNS+c.kind, iriTerm(c.prop))
+		if c.order > 0 {
+			g.add(spec, qbNS+"order", literalTerm(strconv.Itoa(c.order), xsdInteger))
+		}
+	}
+
+	dim := iriTerm(gftdVocab + "resource")
+	g.add(dim, rdfType, iriTerm(qbNS+"DimensionProperty"))
+	g.add(dim, rdfType, iriTerm(qbNS+"CodedProperty"))
+	g.add(dim, rdfsNS+"label", literalTerm("Resource", xsdString))
+	g.add(dim, rdfsNS+"range", iriTerm(skosNS+"Concept"))
+
+	for _, id := range resourceList {
+		node := iriTerm(resourceIRI(id))
+		g.add(node, rdfType, iriTerm(skosNS+"Concept"))
+		g.add(node, skosNS+"notation", literalTerm(id, xsdString))
+		if r, ok := resourcesByID[id]; ok {
+			g.add(node, rdfsNS+"label", literalTerm(r.Name, xsdString))
+			g.add(node, schemaNS+"description", literalTerm(r.Description, xsdString))
+			g.add(node, gftdVocab+"resourceType", literalTerm(r.Type, xsdString))
+		}
+	}
+	for _, code := range regionCodes {
+		node := iriTerm(regionIRI(code))
+		g.add(node, skosNS+"notation", literalTerm(code, xsdString))
+		g.add(node, rdfsNS+"label", literalTerm(regionNames[code], xsdString))
+	}
+
+	for _, v := range ds.Values {
+		obs := iriTerm(observationIRI(v))
+		g.add(obs, rdfType, iriTerm(qbNS+"Observation"))
+		g.add(obs, qbNS+"dataSet", dataset)
+		g.add(obs, gftdVocab+"resource", iriTerm(resourceIRI(v.ResourceID)))
+		g.add(obs, sdmxDimensionNS+"refArea", iriTerm(regionIRI(v.Region)))
+		g.add(obs, sdmxDimensionNS+"refPeriod", iriTerm(yearIRI(v.Year)))
+		g.add(obs, sdmxMeasureNS+"obsValue", literalTerm(formatDecimal(v.Value), xsdDecimal))
+		g.add(obs, dctNS+"source", iriTerm(worldBankIRI))
+		if u, ok := resourceUnit(v.ResourceID); ok {
+			g.add(obs, sdmxAttributeNS+"unitMeasure", iriTerm(unitIRI(u)))
+			g.add(obs, sdmxAttributeNS+"unitMult", literalTerm(strconv.Itoa(u.Mult), xsdInteger))
+		}
+		if v.FetchedAt != "" {
+			g.add(obs, schemaNS+"dateCreated", literalTerm(v.FetchedAt, xsdDateTime))
+		}
+	}
+	return g
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ---------- export formats ----------
+
+// exportFormats maps the format argument of collector.export_jsonld to the
+// media type of its output.
+var exportFormats = map[string]string{
+	"jsonld":           "application/ld+json",
+	"jsonld-compacted": "application/ld+json",
+	"jsonld-expanded":  "application/ld+json",
+	"jsonld-flattened": "application/ld+json",
+	"turtle":           "text/turtle",
+	"ntriples":         "application/n-triples",
+	"nquads":           "application/n-quads",
+}
+
+// serializeGraph renders g in format. JSON-LD formats return a document
+// value; the line-based formats return a string.
+func serializeGraph(g *rdfGraph, format string) (any, error) {
+	ctx := parseJSONLDContext(cubeContext)
+	switch format {
+	case "", "jsonld", "jsonld-compacted":
+		return compactedJSONLD(g, ctx, true), nil
+	case "jsonld-flattened":
+		return compactedJSONLD(g, ctx, false), nil
+	case "jsonld-expanded":
+		return expandedJSONLD(g), nil
+	case "turtle":
+		return writeTurtle(g, ctx.prefixes), nil
+	case "ntriples":
+		return writeNTriples(g), nil
+	case "nquads":
+		return writeNQuads(g, datasetIRI), nil
+	}
+	return nil, fmt.Errorf("unsupported format %q (want one of %s)", format, strings.Join(sortedKeys(exportFormats), ", "))
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ---------- run diffing ----------
+
+type valueKey struct {
+	ResourceID string `json:"resource_id"`
+	Region     string `json:"region"`
+	Year       int    `json:"year"`
+}
+
+type valueChange struct {
+	valueKey
+	From          float64  `json:"from"`
+	To            float64  `json:"to"`
+	AbsoluteDelta float64  `json:"absolute_delta"`
+	RelativeDelta *float64 `json:"relative_delta,omitempty"`
+}
+
+type diffSummary struct {
+	ResourceID       string  `json:"resource_id"`
+	Added            int     `json:"added"`
+	Removed          int     `json:"removed"`
+	Changed          int     `json:"changed"`
+	Unchanged        int     `json:"unchanged"`
+	MaxRelativeDelta float64 `json:"max_abs_relative_delta"`
+}
+
+// diffRuns compares two runs keyed by (resource, region, year). With neither
+// ID it compares the latest two runs; with only a target, the run before it;
+// with only a base, the latest run. Base is the older side of the comparison.
+func diffRuns(args map[string]any) (any, error) {
+	baseID := strVal(args["base_run_id"])
+	targetID := strVal(args["target_run_id"])
+	resourceID := strVal(args["resource_id"])
+
+	mu.RLock()
+	defer mu.RUnlock()
+	var base, target *collectionRun
+	switch {
+	case baseID == "" && targetID == "":
+		if len(runs) < 2 {
+			return nil, fmt.Errorf("need at least two collection runs to diff")
+		}
+		base, target = &runs[len(runs)-2], &runs[len(runs)-1]
+	case baseID == "":
+		i := runIndexLocked(targetID)
+		if i < 0 {
+			return nil, fmt.Errorf("run not found: %s", targetID)
+		}
+		if i == 0 {
+			return nil, fmt.Errorf("run %s has no earlier run to diff against", targetID)
+		}
+		base, target = &runs[i-1], &runs[i]
+	case targetID == "":
+		i := runIndexLocked(baseID)
+		if i < 0 {
+			return nil, fmt.Errorf("run not found: %s", baseID)
+		}
+		if i == len(runs)-1 {
+			return nil, fmt.Errorf("run %s is the latest run; nothing newer to diff against", baseID)
+		}
+		base, target = &runs[i], &runs[len(runs)-1]
+	default:
+		base, target = findRunLocked(baseID), findRunLocked(targetID)
+		if base == nil {
+			return nil, fmt.Errorf("run not found: %s", baseID)
+		}
+		if target == nil {
+			return nil, fmt.Errorf("run not found: %s", targetID)
+		}
+	}
+
+	index := func(values []collectedValue) map[valueKey]float64 {
+		m := make(map[valueKey]float64, len(values))
+		for _, v := range values {
+			if resourceID != "" && v.ResourceID != resourceID {
+				continue
+			}
+			k := valueKey{v.ResourceID, v.Region, v.Year}
+			if _, dup := m[k]; !dup {
+				m[k] = v.Value
+			}
+		}
+		return m
+	}
+	before, after := index(base.Values), index(target.Values)
+
+	added := make([]collectedValue, 0)
+	removed := make([]collectedValue, 0)
+	changed := make([]valueChange, 0)
+	summaries := map[string]*diffSummary{}
+	summaryFor := func(id string) *diffSummary {
+		if s, ok := summaries[id]; ok {
+			return s
+		}
+		s := &diffSummary{ResourceID: id}
+		summaries[id] = s
+		return s
+	}
+
+	for k, to := range after {
+		from, ok := before[k]
+		sum := summaryFor(k.ResourceID)
+		if !ok {
+			added = append(added, collectedValue{ResourceID: k.ResourceID, Region: k.Region, Year: k.Year, Value: to})
+			sum.Added++
+			continue
+		}
+		if from == to {
+			sum.Unchanged++
+			continue
+		}
+		c := valueChange{valueKey: k, From: from, To: to, AbsoluteDelta: to - from}
+		if from != 0 {
+			rel := (to - from) / math.Abs(from)
+			c.RelativeDelta = &rel
+			sum.MaxRelativeDelta = math.Max(sum.MaxRelativeDelta, math.Abs(rel))
+		}
+		changed = append(changed, c)
+		sum.Changed++
+	}
+	for k, from := range before {
+		if _, ok := after[k]; !ok {
+			removed = append(removed, collectedValue{ResourceID: k.ResourceID, Region: k.Region, Year: k.Year, Value: from})
+			summaryFor(k.ResourceID).Removed++
+		}
+	}
+
+	less := func(a, b valueKey) bool {
+		if a.ResourceID != b.ResourceID {
+			return a.ResourceID < b.ResourceID
+		}
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		return a.Year < b.Year
+	}
+	keyOf := func(v collectedValue) valueKey { return valueKey{v.ResourceID, v.Region, v.Year} }
+	sort.Slice(added, func(i, j int) bool { return less(keyOf(added[i]), keyOf(added[j])) })
+	sort.Slice(removed, func(i, j int) bool { return less(keyOf(removed[i]), keyOf(removed[j])) })
+	sort.Slice(changed, func(i, j int) bool { return less(changed[i].valueKey, changed[j].valueKey) })
+
+	perResource := make([]diffSummary, 0, len(summaries))
+	for _, s := range summaries {
+		perResource = append(perResource, *s)
+	}
+	sort.Slice(perResource, func(i, j int) bool { return perResource[i].ResourceID < perResource[j].ResourceID })
+
+	return map[string]any{
+		"base_run_id":   base.ID,
+		"target_run_id": target.ID,
+		"added":         added,
+		"removed":       removed,
+		"changed":       changed,
+		"summary":       perResource,
+		"counts": map[string]int{
+			"added": len(added), "removed": len(removed), "changed": len(changed),
+		},
+	}, nil
+}
+
+// runIndexLocked returns the position of a run in the ring buffer, or -1.
+func runIndexLocked(runID string) int {
+	for i := range runs {
+		if runs[i].ID == runID {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,557 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- resources repository export ----------
+
+// The resources repository keeps one JSON-LD document per observation at the
+// path of its @id (content/resource/{id}/{region}/{year}.jsonld), the
+// qb:DataSet with its structure and code lists at content/resource/collection.jsonld,
+// and content/resource/index.json listing every observation file. Each export
+// is built from a signed snapshot bundle, stored under
+// content/resource/snapshots/{sha256}/, and merged into the parent tree: only
+// observations in the selection's scope are replaced or deleted, and the index
+// and collection are regenerated from the merged tree. Other paths are left
+// untouched.
+const (
+	repoContentDir  = "content/resource/"
+	repoIndexPath   = repoContentDir + "index.json"
+	repoSnapshotDir = repoContentDir + "snapshots/"
+	repoContentIRI  = resourcesBase + repoContentDir
+	defaultBranch   = "main"
+)
+
+type repoChange struct {
+	Path    string `json:"path"`
+	Status  string `json:"status"`
+	OldBlob string `json:"old_blob,omitempty"`
+	NewBlob string `json:"new_blob,omitempty"`
+}
+
+// repoIndex is the content of index.json.
+type repoIndex struct {
+	Dataset      map[string]any      `json:"dataset"`
+	RunIDs       []string            `json:"run_ids"`
+	Created      string              `json:"created"`
+	Snapshots    []repoSnapshotEntry `json:"snapshots"`
+	Observations []repoIndexEntry    `json:"observations"`
+	Count        int                 `json:"count"`
+}
+
+type repoSnapshotEntry struct {
+	ID      string   `json:"id"`
+	Path    string   `json:"path"`
+	RunIDs  []string `json:"run_ids"`
+	Created string   `json:"created"`
+}
+
+type repoIndexEntry struct {
+	ID       string `json:"@id"`
+	Path     string `json:"path"`
+	Resource string `json:"resource"`
+	Region   string `json:"region"`
+	Year     int    `json:"year"`
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// iriPath maps a resources IRI to its file in the repository.
+func iriPath(iri string) string {
+	return strings.TrimPrefix(iri, resourcesBase) + ".jsonld"
+}
+
+// observationKey reads (resource, region, year) back from an observation
+// path; other files under content/resource/ do not match.
+func observationKey(path string) (valueKey, bool) {
+	rest, ok := strings.CutPrefix(path, repoContentDir)
+	if !ok || !strings.HasSuffix(rest, ".jsonld") {
+		return valueKey{}, false
+	}
+	parts := strings.Split(strings.TrimSuffix(rest, ".jsonld"), "/")
+	if len(parts) != 3 || parts[0] == "snapshots" {
+		return valueKey{}, false
+	}
+	year, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return valueKey{}, false
+	}
+	return valueKey{ResourceID: parts[0], Region: strings.ToUpper(parts[1]), Year: year}, true
+}
+
+// snapshotPath is the directory an export stores its bundle in.
+func snapshotPath(snapshotID string) string {
+	return repoSnapshotDir + strings.TrimPrefix(snapshotID, "sha256:") + "/"
+}
+
+// prettyJSON is canonicalJSON indented two spaces, so line diffs between
+// commits stay readable.
+func prettyJSON(v any) ([]byte, error) {
+	norm, err := normalizeJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(norm); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// splitObservations separates the qb:Observation statements of g, keyed by
+// observation IRI, from the rest of the graph.
+func splitObservations(g *rdfGraph) (*rdfGraph, map[string]*rdfGraph) {
+	observations := map[rdfTerm]bool{}
+	for _, t := range g.Triples {
+		if t.P.Value == rdfType && t.O.Value == qbNS+"Observation" {
+			observations[t.S] = true
+		}
+	}
+	rest := &rdfGraph{}
+	perObs := map[string]*rdfGraph{}
+	for _, t := range g.Triples {
+		if !observations[t.S] {
+			rest.Triples = append(rest.Triples, t)
+			continue
+		}
+		if perObs[t.S.Value] == nil {
+			perObs[t.S.Value] = &rdfGraph{}
+		}
+		perObs[t.S.Value].Triples = append(perObs[t.S.Value].Triples, t)
+	}
+	return rest, perObs
+}
+
+// observationValue reads a collected value back from the statements of one
+// observation.
+func observationValue(obs *rdfGraph) collectedValue {
+	var v collectedValue
+	for _, t := range obs.Triples {
+		switch t.P.Value {
+		case gftdVocab + "resource":
+			v.ResourceID = strings.TrimPrefix(t.O.Value, repoContentIRI)
+		case sdmxDimensionNS + "refArea":
+			v.Region = strings.TrimPrefix(t.O.Value, countryBase)
+			v.RegionName = regionLabel(v.Region)
+		case sdmxDimensionNS + "refPeriod":
+			v.Year, _ = strconv.Atoi(strings.TrimPrefix(t.O.Value, yearBase))
+		case sdmxMeasureNS + "obsValue":
+			v.Value, _ = strconv.ParseFloat(t.O.Value, 64)
+		case schemaNS + "dateCreated":
+			v.FetchedAt = t.O.Value
+		}
+	}
+	return v
+}
+
+func regionLabel(code string) string {
+	for _, r := range regions {
+		if strings.EqualFold(r.Code, code) {
+			return r.Name
+		}
+	}
+	return code
+}
+
+func parseJSONLDString(s string) (rdfGraph, error) {
+	var doc any
+	if err := json.Unmarshal([]byte(s), &doc); err != nil {
+		return rdfGraph{}, err
+	}
+	return parseJSONLD(doc)
+}
+
+// exportToRepo builds a signed snapshot of the selection, merges it into a
+// branch of resourcesRepo and returns the commit with a git-am compatible
+// patch against the previous head.
+func exportToRepo(args map[string]any) (any, error) {
+	sel, err := selectionFromArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	branch := strVal(args["branch"])
+	if branch == "" {
+		branch = defaultBranch
+	}
+	if strings.ContainsAny(branch, " ~^:?*[\\") || strings.Contains(branch, "..") {
+		return nil, fmt.Errorf("invalid branch name %q", branch)
+	}
+	authorName := strVal(args["author_name"])
+	if authorName == "" {
+		authorName = "GFTD Resource Collector"
+	}
+	authorEmail := strVal(args["author_email"])
+	if authorEmail == "" {
+		authorEmail = "collector@gftd.ai"
+	}
+	dryRun := boolVal(args["dry_run"])
+
+	bundle, err := buildSnapshot(sel, boolVal(args["allow_ephemeral"]))
+	if err != nil {
+		return nil, err
+	}
+	g, err := parseJSONLDString(bundle.Files[snapshotJSONLD])
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", snapshotJSONLD, err)
+	}
+	_, perObs := splitObservations(&g)
+
+	repo := resourcesRepo
+	parent, err := repo.readRef(branch)
+	if err != nil {
+		return nil, err
+	}
+	oldFiles := map[string]string{}
+	var parentTree string
+	if parent != "" {
+		pc, err := repo.readCommit(parent)
+		if err != nil {
+			return nil, err
+		}
+		parentTree = pc.Tree
+		if oldFiles, err = repo.readTree(pc.Tree); err != nil {
+			return nil, err
+		}
+	}
+	var oldIndex repoIndex
+	if sha, ok := oldFiles[repoIndexPath]; ok {
+		if _, data, err := repo.readObject(sha); err == nil {
+			_ = json.Unmarshal(data, &oldIndex)
+		}
+	}
+
+	// The scope is the selection's filters, narrowed to resources the
+	// selection names or has values for, so a filtered export never removes
+	// observations it did not look at.
+	selected := map[string]bool{}
+	for _, obs := range perObs {
+		selected[observationValue(obs).ResourceID] = true
+	}
+	inScope := func(k valueKey) bool {
+		v := collectedValue{ResourceID: k.ResourceID, Region: k.Region, Year: k.Year}
+		return sel.matches(v) && (selected[k.ResourceID] || containsFold(sel.ResourceIDs, k.ResourceID))
+	}
+
+	newFiles := map[string]string{}
+	origin := map[string]string{}
+	for _, e := range oldIndex.Observations {
+		origin[e.Path] = e.Snapshot
+	}
+	for path, sha := range oldFiles {
+		if k, ok := observationKey(path); ok && inScope(k) {
+			delete(origin, path)
+			continue
+		}
+		if path != repoIndexPath && path != iriPath(datasetIRI) {
+			newFiles[path] = sha
+		}
+	}
+	write := func(path string, content []byte) error {
+		sha, err := repo.writeObject("blob", content)
+		if err == nil {
+			newFiles[path] = sha
+		}
+		return err
+	}
+
+	ctx := parseJSONLDContext(cubeContext)
+	for _, iri := range sortedKeys(perObs) {
+		raw, err := prettyJSON(compactedJSONLD(perObs[iri], ctx, true))
+		if err != nil {
+			return nil, err
+		}
+		if err := write(iriPath(iri), raw); err != nil {
+			return nil, err
+		}
+		origin[iriPath(iri)] = bundle.SnapshotID
+	}
+	dir := snapshotPath(bundle.SnapshotID)
+	for name, content := range bundle.Files {
+		if err := write(dir+name, []byte(content)); err != nil {
+			return nil, err
+		}
+	}
+	sig, err := prettyJSON(map[string]any{"snapshot_id": bundle.SnapshotID, "signature": bundle.Signature})
+	if err != nil {
+		return nil, err
+	}
+	if err := write(dir+"signature.json", sig); err != nil {
+		return nil, err
+	}
+
+	// Regenerate the index and the collection from the merged observations.
+	index := repoIndex{
+		Dataset:      map[string]any{"@id": datasetIRI, "path": iriPath(datasetIRI)},
+		Created:      bundle.Manifest.Created,
+		Snapshots:    []repoSnapshotEntry{},
+		Observations: []repoIndexEntry{},
+	}
+	merged := cubeDataset{DateCreated: bundle.Manifest.Created}
+	for _, path := range sortedKeys(newFiles) {
+		if _, ok := observationKey(path); !ok {
+			continue
+		}
+		_, data, err := repo.readObject(newFiles[path])
+		if err != nil {
+			return nil, err
+		}
+		og, err := parseJSONLDString(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		v := observationValue(&og)
+		merged.Values = append(merged.Values, v)
+		index.Observations = append(index.Observations, repoIndexEntry{
+			ID: observationIRI(v), Path: path, Resource: v.ResourceID, Region: strings.ToUpper(v.Region), Year: v.Year, Snapshot: origin[path],
+		})
+	}
+	known := map[string]repoSnapshotEntry{bundle.SnapshotID: {ID: bundle.SnapshotID, Path: dir, RunIDs: bundle.Manifest.RunIDs, Created: bundle.Manifest.Created}}
+	for _, e := range oldIndex.Snapshots {
+		if _, ok := known[e.ID]; !ok {
+			known[e.ID] = e
+		}
+	}
+	used := map[string]bool{}
+	for _, e := range index.Observations {
+		used[e.Snapshot] = true
+	}
+	runIDs := map[string]bool{}
+	for _, id := range sortedKeys(known) {
+		if !used[id] {
+			// Drop bundles no observation comes from any more; they stay in history.
+			for path := range newFiles {
+				if strings.HasPrefix(path, known[id].Path) {
+					delete(newFiles, path)
+				}
+			}
+			continue
+		}
+		index.Snapshots = append(index.Snapshots, known[id])
+		for _, r := range known[id].RunIDs {
+			runIDs[r] = true
+		}
+	}
+	index.RunIDs = sortedKeys(runIDs)
+	index.Count = len(index.Observations)
+	merged.RunIDs = index.RunIDs
+
+	collection, _ := splitObservations(buildCubeGraph(merged))
+	raw, err := prettyJSON(compactedJSONLD(collection, ctx, true))
+	if err != nil {
+		return nil, err
+	}
+	if err := write(iriPath(datasetIRI), raw); err != nil {
+		return nil, err
+	}
+	if raw, err = prettyJSON(index); err != nil {
+		return nil, err
+	}
+	if err := write(repoIndexPath, raw); err != nil {
+		return nil, err
+	}
+
+	tree, err := repo.writeTree(newFiles)
+	if err != nil {
+		return nil, err
+	}
+	exported := bundle.Manifest.RunIDs
+	if tree == parentTree {
+		return map[string]any{"status": "unchanged", "branch": branch, "commit": parent, "tree": tree, "run_ids": exported, "snapshot_id": bundle.SnapshotID}, nil
+	}
+
+	changes := diffTrees(oldFiles, newFiles)
+	counts := map[string]int{"added": 0, "modified": 0, "deleted": 0}
+	for _, c := range changes {
+		counts[c.Status]++
+	}
+	message := strVal(args["message"])
+	if message == "" {
+		message = fmt.Sprintf("Update resources from %s\n\nSnapshot %s.\n%d added, %d modified, %d deleted.",
+			strings.Join(exported, ", "), bundle.SnapshotID, counts["added"], counts["modified"], counts["deleted"])
+	}
+	// The commit is dated by the data, not the export, so exporting the same
+	// selection onto the same parent always yields the same commit ID.
+	at, err := time.Parse(time.RFC3339, bundle.Manifest.Created)
+	if err != nil {
+		at = time.Now().UTC()
+	}
+	commit := gitCommit{
+		Tree:      tree,
+		Parent:    parent,
+		Author:    gitSignature(authorName, authorEmail, at),
+		Committer: gitSignature(authorName, authorEmail, at),
+		Message:   message,
+	}
+	if commit.SHA, err = repo.writeCommit(commit); err != nil {
+		return nil, err
+	}
+	patch, err := formatPatch(repo, commit, authorName, authorEmail, at, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	status := "dry_run"
+	if !dryRun {
+		if err := repo.updateRef(branch, commit.SHA); err != nil {
+			return nil, err
+		}
+		status = "committed"
+	}
+	return map[string]any{
+		"status":      status,
+		"branch":      branch,
+		"commit":      commit,
+		"run_ids":     exported,
+		"snapshot_id": bundle.SnapshotID,
+		"files":       len(newFiles),
+		"summary":     counts,
+		"changes":     changes,
+		"patch":       patch,
+	}, nil
+}
+
+func diffTrees(oldFiles, newFiles map[string]string) []repoChange {
+	changes := make([]repoChange, 0)
+	for path, sha := range newFiles {
+		switch old, ok := oldFiles[path]; {
+		case !ok:
+			changes = append(changes, repoChange{Path: path, Status: "added", NewBlob: sha})
+		case old != sha:
+			changes = append(changes, repoChange{Path: path, Status: "modified", OldBlob: old, NewBlob: sha})
+		}
+	}
+	for path, sha := range oldFiles {
+		if _, ok := newFiles[path]; !ok {
+			changes = append(changes, repoChange{Path: path, Status: "deleted", OldBlob: sha})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// formatPatch renders the commit as git format-patch output. Each changed file
+// is a single hunk replacing the whole file, which git apply and git am accept.
+func formatPatch(repo gitRepo, c gitCommit, name, email string, at time.Time, changes []repoChange) (string, error) {
+	var b strings.Builder
+	subject, body, _ := strings.Cut(c.Message, "\n")
+	fmt.Fprintf(&b, "From %s Mon Sep 17 00:00:00 2001\n", c.SHA)
+	fmt.Fprintf(&b, "From: %s <%s>\n", name, email)
+	fmt.Fprintf(&b, "Date: %s\n", at.UTC().Format("Mon, 2 Jan 2006 15:04:05 -0700"))
+	fmt.Fprintf(&b, "Subject: [PATCH] %s\n\n", subject)
+	if body = strings.TrimSpace(body); body != "" {
+		b.WriteString(body + "\n")
+	}
+	b.WriteString("---\n\n")
+
+	blobLines := func(sha string) ([]string, error) {
+		if sha == "" {
+			return nil, nil
+		}
+		_, data, err := repo.readObject(sha)
+		if err != nil {
+			return nil, err
+		}
+		return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+	}
+	for _, ch := range changes {
+		oldLines, err := blobLines(ch.OldBlob)
+		if err != nil {
+			return "", err
+		}
+		newLines, err := blobLines(ch.NewBlob)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "diff --git a/%s b/%s\n", ch.Path, ch.Path)
+		switch ch.Status {
+		case "added":
+			fmt.Fprintf(&b, "new file mode %s\nindex 0000000..%s\n--- /dev/null\n+++ b/%s\n", gitModeFile, ch.NewBlob[:7], ch.Path)
+			fmt.Fprintf(&b, "@@ -0,0 +1,%d @@\n", len(newLines))
+		case "deleted":
+			fmt.Fprintf(&b, "deleted file mode %s\nindex %s..0000000\n--- a/%s\n+++ /dev/null\n", gitModeFile, ch.OldBlob[:7], ch.Path)
+			fmt.Fprintf(&b, "@@ -1,%d +0,0 @@\n", len(oldLines))
+		default:
+			fmt.Fprintf(&b, "index %s..%s %s\n--- a/%s\n+++ b/%s\n", ch.OldBlob[:7], ch.NewBlob[:7], gitModeFile, ch.Path, ch.Path)
+			fmt.Fprintf(&b, "@@ -1,%d +1,%d @@\n", len(oldLines), len(newLines))
+		}
+		for _, l := range oldLines {
+			b.WriteString("-" + l + "\n")
+		}
+		for _, l := range newLines {
+			b.WriteString("+" + l + "\n")
+		}
+	}
+	b.WriteString("-- \n" + serviceName + "\n")
+	return b.String(), nil
+}
+
+// repoLog lists commits on a branch, newest first.
+func repoLog(args map[string]any) (any, error) {
+	branch := strVal(args["branch"])
+	if branch == "" {
+		branch = defaultBranch
+	}
+	limit := toInt(args["limit"])
+	if limit <= 0 {
+		limit = 20
+	}
+	sha, err := resourcesRepo.readRef(branch)
+	if err != nil {
+		return nil, err
+	}
+	commits := make([]gitCommit, 0)
+	for sha != "" && len(commits) < limit {
+		c, err := resourcesRepo.readCommit(sha)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, c)
+		sha = c.Parent
+	}
+	return map[string]any{"branch": branch, "commits": commits, "count": len(commits)}, nil
+}
+
+// repoShow returns a file at ref, or the file list of its tree without path.
+func repoShow(args map[string]any) (any, error) {
+	ref := strVal(args["ref"])
+	if ref == "" {
+		ref = defaultBranch
+	}
+	sha, err := resourcesRepo.resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	if sha == "" {
+		return nil, fmt.Errorf("branch %q has no commits", ref)
+	}
+	c, err := resourcesRepo.readCommit(sha)
+	if err != nil {
+		return nil, err
+	}
+	files, err := resourcesRepo.readTree(c.Tree)
+	if err != nil {
+		return nil, err
+	}
+	path := strings.TrimPrefix(strVal(args["path"]), "/")
+	if path == "" {
+		return map[string]any{"commit": c, "files": sortedKeys(files), "count": len(files)}, nil
+	}
+	blob, ok := files[path]
+	if !ok {
+		return nil, fmt.Errorf("path not found at %s: %s", ref, path)
+	}
+	_, data, err := resourcesRepo.readObject(blob)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"commit": c.SHA, "path": path, "blob": blob, "content": string(data)}, nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- git object store ----------
+
+// gitRepo is a bare repository kept in the keyvalue store. Objects are stored
+// exactly as git writes loose objects (zlib of "{type} {len}\x00{content}")
+// under "{prefix}objects/xx/yyyy…", and refs as "{prefix}refs/heads/{branch}",
+// so copying the keys into a directory yields a repository git can read.
+// It stands in for the gftdcojp/resources remote when testing the publishing
+// pipeline offline.
+type gitRepo struct {
+	prefix string
+}
+
+var resourcesRepo = gitRepo{prefix: "git:resources/"}
+
+const (
+	gitModeFile = "100644"
+	gitModeTree = "40000"
+)
+
+type gitCommit struct {
+	SHA       string `json:"sha"`
+	Tree      string `json:"tree"`
+	Parent    string `json:"parent,omitempty"`
+	Author    string `json:"author"`
+	Committer string `json:"committer"`
+	Message   string `json:"message"`
+}
+
+func gitObjectID(kind string, content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", kind, len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (r gitRepo) objectKey(sha string) string {
+	return r.prefix + "objects/" + sha[:2] + "/" + sha[2:]
+}
+
+func (r gitRepo) writeObject(kind string, content []byte) (string, error) {
+	sha := gitObjectID(kind, content)
+	if _, ok, err := store.Get(r.objectKey(sha)); err != nil || ok {
+		return sha, err
+	}
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	fmt.Fprintf(zw, "%s %d\x00", kind, len(content))
+	zw.Write(content)
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return sha, store.Set(r.objectKey(sha), buf.Bytes())
+}
+
+func (r gitRepo) readObject(sha string) (string, []byte, error) {
+	if len(sha) != 40 {
+		return "", nil, fmt.Errorf("invalid object id %q", sha)
+	}
+	raw, ok, err := store.Get(r.objectKey(sha))
+	if err != nil {
+		return "", nil, err
+	}
+	if !ok {
+		return "", nil, fmt.Errorf("object not found: %s", sha)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return "", nil, err
+	}
+	nul := bytes.IndexByte(data, 0)
+	if nul < 0 {
+		return "", nil, fmt.Errorf("object %s: missing header", sha)
+	}
+	kind, size, _ := strings.Cut(string(data[:nul]), " ")
+	if n, err := strconv.Atoi(size); err != nil || n != len(data)-nul-1 {
+		return "", nil, fmt.Errorf("object %s: bad size", sha)
+	}
+	return kind, data[nul+1:], nil
+}
+
+func (r gitRepo) readRef(branch string) (string, error) {
+	raw, ok, err := store.Get(r.prefix + "refs/heads/" + branch)
+	if err != nil || !ok {
+		return "", err
+	}
+	return strings.TrimSpace(string(raw)), nil
+}
+
+func (r gitRepo) updateRef(branch, sha string) error {
+	if err := store.Set(r.prefix+"refs/heads/"+branch, []byte(sha+"\n")); err != nil {
+		return err
+	}
+	return store.Set(r.prefix+"HEAD", []byte("ref: refs/heads/"+branch+"\n"))
+}
+
+// resolve accepts a branch name or a full commit ID.
+func (r gitRepo) resolve(ref string) (string, error) {
+	if sha, err := r.readRef(ref); err != nil || sha != "" {
+		return sha, err
+	}
+	if len(ref) == 40 {
+		return ref, nil
+	}
+	return "", fmt.Errorf("unknown ref %q", ref)
+}
+
+// writeTree stores the nested trees for files (path → blob ID) and returns
+// the root tree ID.
+func (r gitRepo) writeTree(files map[string]string) (string, error) {
+	blobs := map[string]string{}
+	dirs := map[string]map[string]string{}
+	for path, sha := range files {
+		dir, rest, nested := strings.Cut(path, "/")
+		if !nested {
+			blobs[path] = sha
+			continue
+		}
+		if dirs[dir] == nil {
+			dirs[dir] = map[string]string{}
+		}
+		dirs[dir][rest] = sha
+	}
+	type entry struct{ mode, name, sha string }
+	entries := make([]entry, 0, len(blobs)+len(dirs))
+	for name, sha := range blobs {
+		entries = append(entries, entry{gitModeFile, name, sha})
+	}
+	for name, sub := range dirs {
+		sha, err := r.writeTree(sub)
+		if err != nil {
+			return "", err
+		}
+		entries = append(entries, entry{gitModeTree, name, sha})
+	}
+	// git orders tree entries by name, comparing directories as "name/".
+	sortName := func(e entry) string {
+		if e.mode == gitModeTree {
+			return e.name + "/"
+		}
+		return e.name
+	}
+	sort.Slice(entries, func(i, j int) bool { return sortName(entries[i]) < sortName(entries[j]) })
+	var buf bytes.Buffer
+	for _, e := range entries {
+		raw, _ := hex.DecodeString(e.sha)
+		fmt.Fprintf(&buf, "%s %s\x00", e.mode, e.name)
+		buf.Write(raw)
+	}
+	return r.writeObject("tree", buf.Bytes())
+}
+
+// readTree flattens a tree into path → blob ID.
+func (r gitRepo) readTree(sha string) (map[string]string, error) {
+	out := map[string]string{}
+	var walk func(sha, base string) error
+	walk = func(sha, base string) error {
+		kind, data, err := r.readObject(sha)
+		if err != nil {
+			return err
+		}
+		if kind != "tree" {
+			return fmt.Errorf("object %s is a %s, not a tree", sha, kind)
+		}
+		for len(data) > 0 {
+			nul := bytes.IndexByte(data, 0)
+			if nul < 0 || len(data) < nul+21 {
+				return fmt.Errorf("tree %s: truncated entry", sha)
+			}
+			mode, name, _ := strings.Cut(string(data[:nul]), " ")
+			id := hex.EncodeToString(data[nul+1 : nul+21])
+			data = data[nul+21:]
+			if mode == gitModeTree {
+				if err := walk(id, base+name+"/"); err != nil {
+					return err
+				}
+			} else {
+				out[base+name] = id
+			}
+		}
+		return nil
+	}
+	return out, walk(sha, "")
+}
+
+// gitSignature formats an author or committer line value.
+func gitSignature(name, email string, at time.Time) string {
+	return fmt.Sprintf("%s <%s> %d +0000", name, email, at.Unix())
+}
+
+func (r gitRepo) writeCommit(c gitCommit) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "tree %s\n", c.Tree)
+	if c.Parent != "" {
+		fmt.Fprintf(&b, "parent %s\n", c.Parent)
+	}
+	fmt.Fprintf(&b, "author %s\ncommitter %s\n\n%s\n", c.Author, c.Committer, strings.TrimRight(c.Message, "\n"))
+	return r.writeObject("commit", []byte(b.String()))
+}
+
+func (r gitRepo) readCommit(sha string) (gitCommit, error) {
+	kind, data, err := r.readObject(sha)
+	if err != nil {
+		return gitCommit{}, err
+	}
+	if kind != "commit" {
+		return gitCommit{}, fmt.Errorf("object %s is a %s, not a commit", sha, kind)
+	}
+	c := gitCommit{SHA: sha}
+	header, msg, _ := strings.Cut(string(data), "\n\n")
+	c.Message = strings.TrimRight(msg, "\n")
+	for _, line := range strings.Split(header, "\n") {
+		key, val, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			c.Tree = val
+		case "parent":
+			c.Parent = val
+		case "author":
+			c.Author = val
+		case "committer":
+			c.Committer = val
+		}
+	}
+	return c, nil
+}
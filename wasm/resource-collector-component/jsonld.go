@@ -0,0 +1,445 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ---------- JSON-LD ----------
+
+// jsonldTerm is a parsed term definition from an @context map.
+type jsonldTerm struct {
+	IRI       string
+	Type      string
+	Container string
+}
+
+type jsonldContext struct {
+	raw      map[string]any
+	terms    map[string]jsonldTerm
+	prefixes map[string]string
+}
+
+// parseJSONLDContext handles the context features the exports use: prefixes,
+// simple and expanded term definitions, @type coercion and @set containers.
+func parseJSONLDContext(raw map[string]any) jsonldContext {
+	ctx := jsonldContext{raw: raw, terms: map[string]jsonldTerm{}, prefixes: map[string]string{}}
+	for k, v := range raw {
+		if s, ok := v.(string); ok && strings.Contains(s, "://") && (strings.HasSuffix(s, "/") || strings.HasSuffix(s, "#")) {
+			ctx.prefixes[k] = s
+		}
+	}
+	for k, v := range raw {
+		switch def := v.(type) {
+		case string:
+			ctx.terms[k] = jsonldTerm{IRI: ctx.expandPrefix(def)}
+		case map[string]any:
+			t := jsonldTerm{IRI: ctx.expandPrefix(strVal(def["@id"])), Container: strVal(def["@container"])}
+			if typ := strVal(def["@type"]); typ != "" {
+				if !strings.HasPrefix(typ, "@") {
+					typ = ctx.expandPrefix(typ)
+				}
+				t.Type = typ
+			}
+			ctx.terms[k] = t
+		}
+	}
+	return ctx
+}
+
+func (c jsonldContext) expandPrefix(v string) string {
+	if i := strings.IndexByte(v, ':'); i > 0 && !strings.HasPrefix(v[i:], "://") {
+		if ns, ok := c.prefixes[v[:i]]; ok {
+			return ns + v[i+1:]
+		}
+	}
+	return v
+}
+
+// expandIRI expands v; with vocab set, bare terms are resolved too (as for
+// keys and @type values).
+func (c jsonldContext) expandIRI(v string, vocab bool) string {
+	if vocab {
+		if t, ok := c.terms[v]; ok && t.IRI != "" {
+			return t.IRI
+		}
+	}
+	return c.expandPrefix(v)
+}
+
+func (c jsonldContext) compactIRI(iri string) string {
+	best, bestLen := "", 0
+	for p, ns := range c.prefixes {
+		if len(ns) > bestLen && strings.HasPrefix(iri, ns) && isPlainLocalName(iri[len(ns):]) && iri != ns {
+			best, bestLen = p, len(ns)
+		}
+	}
+	if bestLen > 0 {
+		return best + ":" + iri[bestLen:]
+	}
+	return iri
+}
+
+// selectTerm picks the key and compacted value for one statement: a term whose
+// coercion matches the object if there is one, else an untyped term or the
+// compact IRI with an explicit value object.
+func (c jsonldContext) selectTerm(pred string, o rdfTerm) (string, any, bool) {
+	var untyped string
+	for _, name := range sortedKeys(c.terms) {
+		t := c.terms[name]
+		if t.IRI != pred {
+			continue
+		}
+		switch {
+		case t.Type == "@id" && o.Kind != termLiteral:
+			return name, c.compactRef(o), t.Container == "@set"
+		case t.Type != "" && o.Kind == termLiteral && o.Lang == "" && t.Type == o.Datatype:
+			return name, o.Value, t.Container == "@set"
+		case t.Type == "" && o.Kind == termLiteral && o.Datatype == xsdString:
+			return name, o.Value, t.Container == "@set"
+		case t.Type == "" && untyped == "":
+			untyped = name
+		}
+	}
+	key := untyped
+	if key == "" {
+		key = c.compactIRI(pred)
+		if o.Kind == termLiteral && o.Datatype == xsdString {
+			return key, o.Value, false
+		}
+	}
+	set := c.terms[key].Container == "@set"
+	switch {
+	case o.Kind != termLiteral:
+		return key, map[string]any{"@id": c.compactRef(o)}, set
+	case o.Lang != "":
+		return key, map[string]any{"@value": o.Value, "@language": o.Lang}, set
+	}
+	return key, map[string]any{"@value": o.Value, "@type": c.compactIRI(o.Datatype)}, set
+}
+
+func (c jsonldContext) compactRef(o rdfTerm) string {
+	if o.Kind == termBlank {
+		return "_:" + o.Value
+	}
+	return c.compactIRI(o.Value)
+}
+
+// graphNodes indexes triples by subject and records which blank nodes are
+// referenced exactly once, so they can be embedded in their referrer.
+type graphNodes struct {
+	order   []rdfTerm
+	props   map[rdfTerm][]rdfTriple
+	inbound map[rdfTerm]int
+}
+
+func indexGraph(g *rdfGraph) graphNodes {
+	n := graphNodes{order: g.subjects(), props: map[rdfTerm][]rdfTriple{}, inbound: map[rdfTerm]int{}}
+	seen := map[rdfTriple]bool{}
+	for _, t := range g.Triples {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		n.props[t.S] = append(n.props[t.S], t)
+		if t.O.Kind == termBlank {
+			n.inbound[t.O]++
+		}
+	}
+	return n
+}
+
+func (n graphNodes) embeddable(t rdfTerm) bool {
+	return t.Kind == termBlank && n.inbound[t] == 1 && len(n.props[t]) > 0
+}
+
+// expandedJSONLD renders g in JSON-LD expanded form: full IRIs, every value in
+// an array, single-use blank nodes nested in place.
+func expandedJSONLD(g *rdfGraph) []any {
+	n := indexGraph(g)
+	var node func(s rdfTerm, top bool) map[string]any
+	node = func(s rdfTerm, top bool) map[string]any {
+		obj := map[string]any{}
+		if s.Kind == termIRI {
+			obj["@id"] = s.Value
+		} else if top {
+			obj["@id"] = "_:" + s.Value
+		}
+		for _, t := range n.props[s] {
+			p := t.P.Value
+			if p == rdfType {
+				types, _ := obj["@type"].([]any)
+				obj["@type"] = append(types, t.O.Value)
+				continue
+			}
+			var v any
+			switch {
+			case n.embeddable(t.O):
+				v = node(t.O, false)
+			case t.O.Kind == termIRI:
+				v = map[string]any{"@id": t.O.Value}
+			case t.O.Kind == termBlank:
+				v = map[string]any{"@id": "_:" + t.O.Value}
+			case t.O.Lang != "":
+				v = map[string]any{"@value": t.O.Value, "@language": t.O.Lang}
+			case t.O.Datatype == xsdString:
+				v = map[string]any{"@value": t.O.Value}
+			default:
+				v = map[string]any{"@value": t.O.Value, "@type": t.O.Datatype}
+			}
+			vals, _ := obj[p].([]any)
+			obj[p] = append(vals, v)
+		}
+		return obj
+	}
+	out := make([]any, 0, len(n.order))
+	for _, s := range n.order {
+		if !n.embeddable(s) {
+			out = append(out, node(s, true))
+		}
+	}
+	return out
+}
+
+// compactedJSONLD renders g against ctx with a single top-level @context.
+// With embed set, single-use blank nodes are nested in their referrer;
+// otherwise every node is a top-level @graph entry (flattened form).
+func compactedJSONLD(g *rdfGraph, ctx jsonldContext, embed bool) map[string]any {
+	n := indexGraph(g)
+	var node func(s rdfTerm, top bool) map[string]any
+	node = func(s rdfTerm, top bool) map[string]any {
+		obj := map[string]any{}
+		if s.Kind == termIRI {
+			obj["@id"] = s.Value
+		} else if top || !embed {
+			obj["@id"] = "_:" + s.Value
+		}
+		types := make([]string, 0)
+		sets := map[string]bool{}
+		multi := map[string][]any{}
+		keys := make([]string, 0)
+		for _, t := range n.props[s] {
+			if t.P.Value == rdfType {
+				types = append(types, ctx.compactIRI(t.O.Value))
+				continue
+			}
+			key, v, set := ctx.selectTerm(t.P.Value, t.O)
+			if embed && n.embeddable(t.O) {
+				v = node(t.O, false)
+			}
+			sets[key] = sets[key] || set
+			if _, ok := multi[key]; !ok {
+				keys = append(keys, key)
+			}
+			multi[key] = append(multi[key], v)
+		}
+		switch len(types) {
+		case 0:
+		case 1:
+			obj["@type"] = types[0]
+		default:
+			obj["@type"] = types
+		}
+		for _, k := range keys {
+			if len(multi[k]) == 1 && !sets[k] {
+				obj[k] = multi[k][0]
+			} else {
+				obj[k] = multi[k]
+			}
+		}
+		return obj
+	}
+	graph := make([]any, 0, len(n.order))
+	for _, s := range n.order {
+		if !embed || !n.embeddable(s) {
+			graph = append(graph, node(s, true))
+		}
+	}
+	return map[string]any{"@context": ctx.raw, "@graph": graph}
+}
+
+// parseJSONLD converts a JSON-LD document back into triples. It understands
+// the shapes the exports produce: a map-valued top-level @context, @graph,
+// expanded arrays, nested nodes, value objects and term coercion.
+func parseJSONLD(doc any) (rdfGraph, error) {
+	p := &jsonldParser{ctx: parseJSONLDContext(map[string]any{})}
+	var nodes []any
+	switch d := doc.(type) {
+	case []any:
+		nodes = d
+	case map[string]any:
+		if rawCtx, ok := d["@context"]; ok {
+			m, ok := rawCtx.(map[string]any)
+			if !ok {
+				return p.g, fmt.Errorf("only inline object @context is supported")
+			}
+			p.ctx = parseJSONLDContext(m)
+		}
+		if graph, ok := d["@graph"].([]any); ok {
+			nodes = graph
+		} else {
+			nodes = []any{d}
+		}
+	default:
+		return p.g, fmt.Errorf("unexpected JSON-LD document of type %T", doc)
+	}
+	for _, n := range nodes {
+		obj, ok := n.(map[string]any)
+		if !ok {
+			return p.g, fmt.Errorf("top-level node must be an object")
+		}
+		if _, err := p.node(obj); err != nil {
+			return p.g, err
+		}
+	}
+	return p.g, nil
+}
+
+type jsonldParser struct {
+	ctx   jsonldContext
+	g     rdfGraph
+	blank int
+}
+
+func (p *jsonldParser) subjectTerm(id string) rdfTerm {
+	if strings.HasPrefix(id, "_:") {
+		return blankTerm(id[2:])
+	}
+	return iriTerm(p.ctx.expandIRI(id, false))
+}
+
+func (p *jsonldParser) node(obj map[string]any) (rdfTerm, error) {
+	var subj rdfTerm
+	if id, ok := obj["@id"].(string); ok {
+		subj = p.subjectTerm(id)
+	} else {
+		p.blank++
+		subj = blankTerm(fmt.Sprintf("gen%d", p.blank))
+	}
+	for _, key := range sortedKeys(obj) {
+		val := obj[key]
+		switch key {
+		case "@id", "@context":
+			continue
+		case "@type":
+			for _, t := range asList(val) {
+				s, ok := t.(string)
+				if !ok {
+					return subj, fmt.Errorf("@type values must be strings")
+				}
+				p.g.add(subj, rdfType, iriTerm(p.ctx.expandIRI(s, true)))
+			}
+			continue
+		}
+		if strings.HasPrefix(key, "@") {
+			return subj, fmt.Errorf("unsupported keyword %s", key)
+		}
+		def := p.ctx.terms[key]
+		pred := def.IRI
+		if pred == "" {
+			pred = p.ctx.expandPrefix(key)
+		}
+		if !strings.Contains(pred, ":") {
+			continue // undefined terms are dropped, as by a JSON-LD processor
+		}
+		for _, v := range asList(val) {
+			o, ok, err := p.value(v, def)
+			if err != nil {
+				return subj, fmt.Errorf("%s: %w", key, err)
+			}
+			if ok {
+				p.g.add(subj, pred, o)
+			}
+		}
+	}
+	return subj, nil
+}
+
+func (p *jsonldParser) value(v any, def jsonldTerm) (rdfTerm, bool, error) {
+	switch t := v.(type) {
+	case nil:
+		return rdfTerm{}, false, nil
+	case string:
+		switch def.Type {
+		case "@id":
+			return p.subjectTerm(t), true, nil
+		case "@vocab":
+			return iriTerm(p.ctx.expandIRI(t, true)), true, nil
+		case "":
+			return literalTerm(t, xsdString), true, nil
+		}
+		return literalTerm(t, def.Type), true, nil
+	case bool:
+		return literalTerm(strconv.FormatBool(t), xsdBoolean), true, nil
+	case float64:
+		lex, dt := jsonNumberLexical(t)
+		if def.Type != "" && def.Type != "@id" && def.Type != "@vocab" {
+			dt = def.Type
+		}
+		return literalTerm(lex, dt), true, nil
+	case map[string]any:
+		if raw, ok := t["@value"]; ok {
+			lex := fmt.Sprint(raw)
+			dt := xsdString
+			switch rv := raw.(type) {
+			case float64:
+				lex, dt = jsonNumberLexical(rv)
+			case bool:
+				dt = xsdBoolean
+			}
+			if lang := strVal(t["@language"]); lang != "" {
+				return langTerm(lex, lang), true, nil
+			}
+			if typ := strVal(t["@type"]); typ != "" {
+				dt = p.ctx.expandIRI(typ, true)
+			}
+			return literalTerm(lex, dt), true, nil
+		}
+		if _, ok := t["@list"]; ok {
+			return rdfTerm{}, false, fmt.Errorf("@list is not supported")
+		}
+		if id, ok := t["@id"].(string); ok && len(t) == 1 {
+			return p.subjectTerm(id), true, nil
+		}
+		s, err := p.node(t)
+		return s, err == nil, err
+	}
+	return rdfTerm{}, false, fmt.Errorf("unsupported value of type %T", v)
+}
+
+// jsonNumberLexical applies the JSON-LD number-to-RDF conversion: integral
+// values become xsd:integer, others xsd:double in canonical form.
+func jsonNumberLexical(v float64) (string, string) {
+	if v == math.Trunc(v) && math.Abs(v) < 1e21 {
+		return strconv.FormatFloat(v, 'f', 0, 64), xsdInteger
+	}
+	s := strconv.FormatFloat(v, 'E', -1, 64)
+	mant, exp, _ := strings.Cut(s, "E")
+	if !strings.Contains(mant, ".") {
+		mant += ".0"
+	}
+	e, _ := strconv.Atoi(exp)
+	return mant + "E" + strconv.Itoa(e), xsdDouble
+}
+
+func asList(v any) []any {
+	if list, ok := v.([]any); ok {
+		return list
+	}
+	return []any{v}
+}
+
+// normalizeJSON round-trips v through encoding/json so documents built from
+// typed Go values look exactly as a client would decode them.
+func normalizeJSON(v any) (any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	err = json.Unmarshal(raw, &out)
+	return out, err
+}
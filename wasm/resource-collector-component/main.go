@@ -6,6 +6,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -38,31 +39,27 @@ type collectedValue struct {
 	Unit       string  `json:"unit"`
 	Source     string  `json:"source"`
 	FetchedAt  string  `json:"fetched_at"`
+	// Quality is empty for rows that passed validation, else "flagged" or
+	// "quarantined"; QualityFlags names the failed checks.
+	Quality      string   `json:"quality,omitempty"`
+	QualityFlags []string `json:"quality_flags,omitempty"`
 }
 
 type collectionRun struct {
-	ID         string           `json:"id"`
-	StartedAt  string           `json:"started_at"`
-	FinishedAt string           `json:"finished_at,omitempty"`
-	Status     string           `json:"status"`
-	Resources  int              `json:"resources_requested"`
-	Collected  int              `json:"values_collected"`
-	Errors     []string         `json:"errors,omitempty"`
-	Values     []collectedValue `json:"values,omitempty"`
-}
-
-type jsonldResource struct {
-	Context     string  `json:"@context"`
-	Type        string  `json:"@type"`
-	ID          string  `json:"@id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Region      string  `json:"spatialCoverage,omitempty"`
-	Year        int     `json:"temporalCoverage,omitempty"`
-	Value       float64 `json:"value,omitempty"`
-	Unit        string  `json:"unitCode,omitempty"`
-	Source      string  `json:"isBasedOn,omitempty"`
-	DateCreated string  `json:"dateCreated,omitempty"`
+	ID          string           `json:"id"`
+	StartedAt   string           `json:"started_at"`
+	FinishedAt  string           `json:"finished_at,omitempty"`
+	Status      string           `json:"status"`
+	TraceID     string           `json:"trace_id,omitempty"`
+	Resources   int              `json:"resources_requested"`
+	Collected   int              `json:"values_collected"`
+	Errors      []runError       `json:"errors,omitempty"`
+	Values      []collectedValue `json:"values,omitempty"`
+	Quality     *qualityReport   `json:"quality,omitempty"`
+	Quarantined []collectedValue `json:"quarantined,omitempty"`
+	// SourceVersions maps each World Bank indicator fetched in the run to
+	// the lastupdated date the API reported for it.
+	SourceVersions map[string]string `json:"source_versions,omitempty"`
 }
 
 // ---------- MCP types ----------
@@ -143,6 +140,20 @@ var (
 			Description: "Get the status of recent collection runs.",
 			InputSchema: map[string]any{"type": "object", "properties": map[string]any{}},
 		},
+		{
+			Name:        "collector.run_errors",
+			Description: "Query typed error records from a collection run (latest by default), filtered by class, resource, region or source.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"run_id":      map[string]any{"type": "string"},
+					"class":       map[string]any{"type": "string", "enum": []string{errClassNetwork, errClassHTTP, errClassParse, errClassNoData, errClassCircuitOpen, errClassUpstream, errClassRequest}},
+					"resource_id": map[string]any{"type": "string"},
+					"region":      map[string]any{"type": "string"},
+					"source":      map[string]any{"type": "string"},
+				},
+			},
+		},
 		{
 			Name:        "collector.list_catalog",
 			Description: "List all resource definitions in the collection catalog.",
@@ -159,13 +170,107 @@ var (
 				},
 			},
 		},
+		{
+			Name:        "collector.diff_runs",
+			Description: "Diff two collection runs (default: the latest two), returning added, removed and changed values keyed by resource, region and year.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"base_run_id":   map[string]any{"type": "string", "description": "Older run (default: the run before target_run_id, or the second-latest)"},
+					"target_run_id": map[string]any{"type": "string", "description": "Newer run (default: latest)"},
+					"resource_id":   map[string]any{"type": "string", "description": "Optional filter"},
+				},
+			},
+		},
 		{
 			Name:        "collector.export_jsonld",
-			Description: "Export collected data as JSON-LD for publishing to the resources repository.",
+			Description: "Export collected data as RDF (Data Cube) for publishing to the resources repository, from one run or consolidated across runs (mode=latest) and filtered by resource, region and year range. The default compacted JSON-LD is returned as the document itself; other formats return {format, media_type, document|content}.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": withSelection(map[string]any{
+					"format": map[string]any{"type": "string", "enum": []string{"jsonld", "jsonld-compacted", "jsonld-expanded", "jsonld-flattened", "turtle", "ntriples", "nquads"}, "description": "Serialization (default: jsonld, compacted)"},
+				}),
+			},
+		},
+		{
+			Name:        "collector.export_table",
+			Description: "Export collected values as CSV or TSV, in long format or a wide pivot with one column per year. Also downloadable via GET /export/{run_id|latest}.{csv|tsv}.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": withSelection(map[string]any{
+					"format":   map[string]any{"type": "string", "enum": []string{"csv", "tsv"}, "description": "Default: csv"},
+					"layout":   map[string]any{"type": "string", "enum": []string{layoutLong, layoutWide}, "description": "long: one row per value; wide: one row per resource/region with years as columns"},
+					"columns":  map[string]any{"type": "array", "items": map[string]any{"type": "string", "enum": tableColumns}, "description": "Columns to include (wide layout: row identifier columns only)"},
+					"metadata": map[string]any{"type": "boolean", "description": "Prefix the table with '#' header metadata lines"},
+				}),
+			},
+		},
+		{
+			Name:        "collector.export_sdmx",
+			Description: "Export collected values as an SDMX data message (SDMX-JSON 1.0, or SDMX-ML 2.1 GenericData with its Structure message), generated and validated against a DSD with dimensions RESOURCE, REF_AREA, TIME_PERIOD.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": withSelection(map[string]any{
+					"format": map[string]any{"type": "string", "enum": []string{"sdmx-json", "sdmx-ml"}, "description": "Default: sdmx-json"},
+				}),
+			},
+		},
+		{
+			Name:        "collector.snapshot",
+			Description: "Build a deterministic, content-addressed snapshot of collected values for the resources repository: canonical JSON-LD, CSV and a manifest with the SHA-256 of each file, the run IDs and source versions, signed with Ed25519. The snapshot ID is the SHA-256 of the manifest. Requires COLLECTOR_SIGNING_KEY unless allow_ephemeral is set.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": withSelection(map[string]any{
+					"allow_ephemeral": map[string]any{"type": "boolean", "description": "Sign with a key generated for the life of the process when COLLECTOR_SIGNING_KEY is not set; such snapshots only verify as trusted with a pinned public_key"},
+				}),
+			},
+		},
+		{
+			Name:        "collector.verify_snapshot",
+			Description: "Verify a snapshot bundle's file hashes, snapshot ID and Ed25519 signature. Pass the bundle returned by collector.snapshot, or the snapshot_id of one created by this collector. ok requires a valid signature by the pinned public_key or the configured signing key.",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
-					"resource_id": map[string]any{"type": "string"},
+					"bundle":      map[string]any{"type": "object", "description": "Bundle as returned by collector.snapshot"},
+					"snapshot_id": map[string]any{"type": "string"},
+					"public_key":  map[string]any{"type": "string", "description": "Base64 Ed25519 public key the bundle must be signed with; without it only the configured signing key is trusted"},
+				},
+			},
+		},
+		{
+			Name:        "collector.git_export",
+			Description: "Build a signed snapshot of the selection and commit it to a branch of the local bare repository stand-in in the resources repository layout: content/resource/{id}/{region}/{year}.jsonld at each observation's @id, the bundle under content/resource/snapshots/{sha256}/, and collection.jsonld and index.json regenerated from the merged tree. Observations outside the selection's resources, regions and years are kept. Returns the commit and a git format-patch against the previous head.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": withSelection(map[string]any{
+					"branch":          map[string]any{"type": "string", "description": "Default: main"},
+					"message":         map[string]any{"type": "string", "description": "Commit message (default: generated from run IDs and change counts)"},
+					"author_name":     map[string]any{"type": "string"},
+					"author_email":    map[string]any{"type": "string"},
+					"dry_run":         map[string]any{"type": "boolean", "description": "Build the commit and patch without moving the branch"},
+					"allow_ephemeral": map[string]any{"type": "boolean", "description": "Sign with a process-lifetime key when COLLECTOR_SIGNING_KEY is not set"},
+				}),
+			},
+		},
+		{
+			Name:        "collector.git_log",
+			Description: "List commits on a branch of the resources repository stand-in, newest first.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"branch": map[string]any{"type": "string", "description": "Default: main"},
+					"limit":  map[string]any{"type": "integer", "description": "Default: 20"},
+				},
+			},
+		},
+		{
+			Name:        "collector.git_show",
+			Description: "Read a file from the resources repository stand-in at a branch or commit, or list the files of its tree when path is omitted.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"ref":  map[string]any{"type": "string", "description": "Branch name or commit ID (default: main)"},
+					"path": map[string]any{"type": "string"},
 				},
 			},
 		},
@@ -175,10 +280,43 @@ var (
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
-					"target_mcp_url": map[string]any{"type": "string", "description": "MCP endpoint to publish to (default: global-mcp-component)"},
+					"target_mcp_url":  map[string]any{"type": "string", "description": "MCP endpoint to publish to (default: global-mcp-component)"},
+					"include_flagged": map[string]any{"type": "boolean", "description": "Include rows flagged by quality validation (default: false)"},
 				},
 			},
 		},
+		{
+			Name:        "collector.quality_report",
+			Description: "Get the data quality report and quarantined rows for a collection run (latest by default).",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"run_id": map[string]any{"type": "string"},
+				},
+			},
+		},
+		{
+			Name:        "collector.list_quality_rules",
+			Description: "List the data quality rules in force for each cataloged resource.",
+			InputSchema: map[string]any{"type": "object", "properties": map[string]any{}},
+		},
+		{
+			Name:        "collector.set_quality_rule",
+			Description: "Replace the data quality rule for a resource. Applies to subsequent runs.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"resource_id":       map[string]any{"type": "string"},
+					"min":               map[string]any{"type": "number", "description": "Lower bound (inclusive)"},
+					"max":               map[string]any{"type": "number", "description": "Upper bound (inclusive)"},
+					"max_yoy_change":    map[string]any{"type": "number", "description": "Max relative year-over-year change, e.g. 0.5 for 50% (0 disables)"},
+					"required_years":    map[string]any{"type": "array", "items": map[string]any{"type": "integer"}},
+					"reject_duplicates": map[string]any{"type": "boolean", "description": "Flag repeated resource/region/year rows (default: true)"},
+					"action":            map[string]any{"type": "string", "enum": []string{qualityFlag, qualityQuarantine}},
+				},
+				"required": []string{"resource_id"},
+			},
+		},
 	}
 )
 
@@ -188,34 +326,50 @@ func main() {}
 // ---------- routing ----------
 
 func routeHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	tc := traceFromRequest(r)
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	w = rec
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Trace-Id", tc.TraceID)
+	w.Header().Set("traceresponse", tc.header())
+	defer func() {
+		logEvent(tc, "info", "request", map[string]any{
+			"method": r.Method, "path": r.URL.Path, "status": rec.status,
+			"duration_ms": time.Since(start).Milliseconds(),
+		})
+	}()
 	if handleCORS(w, r) {
 		return
 	}
 	path := normalizePath(r.URL.Path)
 	switch {
-	case path == "/healthz" || path == "/readyz":
+	case path == "/healthz":
 		writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "service": "resource-collector-component"})
+	case path == "/readyz":
+		handleReadyz(w, r)
 	case path == "/api/mcp":
-		handleMCP(w, r)
+		handleMCP(w, r, tc)
+	case strings.HasPrefix(path, "/export/"):
+		handleExportDownload(w, r, path)
 	case path == "/scheduler/trigger":
-		handleSchedulerTrigger(w, r)
+		handleSchedulerTrigger(w, r, tc)
 	default:
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
 	}
 }
 
 // handleSchedulerTrigger is called by the scheduler on cron cadence
-func handleSchedulerTrigger(w http.ResponseWriter, r *http.Request) {
+func handleSchedulerTrigger(w http.ResponseWriter, r *http.Request, tc traceContext) {
 	if r.Method != http.MethodPost {
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST only"})
 		return
 	}
-	run := executeCollection(nil, 0)
-	writeJSON(w, http.StatusOK, map[string]any{"status": "triggered", "run_id": run.ID, "collected": run.Collected})
+	run := executeCollection(tc, nil, 0)
+	writeJSON(w, http.StatusOK, map[string]any{"status": "triggered", "run_id": run.ID, "collected": run.Collected, "trace_id": tc.TraceID})
 }
 
-func handleMCP(w http.ResponseWriter, r *http.Request) {
+func handleMCP(w http.ResponseWriter, r *http.Request, tc traceContext) {
 	if r.Method != http.MethodPost {
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 		return
@@ -233,8 +387,9 @@ func handleMCP(w http.ResponseWriter, r *http.Request) {
 	case "tools/list":
 		writeJSON(w, http.StatusOK, mcpResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{"tools": tools}})
 	case "tools/call":
-		result, err := callTool(req.Params.Name, req.Params.Arguments)
+		result, err := callTool(tc, req.Params.Name, req.Params.Arguments)
 		if err != nil {
+			logEvent(tc, "warn", "tool call failed", map[string]any{"tool": req.Params.Name, "error": err})
 			writeJSON(w, http.StatusOK, mcpResponse{JSONRPC: "2.0", ID: req.ID, Error: &mcpError{Code: -32000, Message: err.Error()}})
 			return
 		}
@@ -246,7 +401,7 @@ func handleMCP(w http.ResponseWriter, r *http.Request) {
 
 // ---------- tool dispatch ----------
 
-func callTool(name string, args map[string]any) (any, error) {
+func callTool(tc traceContext, name string, args map[string]any) (any, error) {
 	if args == nil {
 		args = map[string]any{}
 	}
@@ -254,7 +409,7 @@ func callTool(name string, args map[string]any) (any, error) {
 	case "collector.run":
 		resourceIDs := toStringSlice(args["resource_ids"])
 		year := toInt(args["year"])
-		run := executeCollection(resourceIDs, year)
+		run := executeCollection(tc, resourceIDs, year)
 		return map[string]any{"run": run}, nil
 
 	case "collector.status":
@@ -269,12 +424,19 @@ func callTool(name string, args map[string]any) (any, error) {
 		for i, r := range recent {
 			summaries[i] = map[string]any{
 				"id": r.ID, "started_at": r.StartedAt, "finished_at": r.FinishedAt,
-				"status": r.Status, "resources_requested": r.Resources,
+				"status": r.Status, "trace_id": r.TraceID, "resources_requested": r.Resources,
 				"values_collected": r.Collected, "error_count": len(r.Errors),
+				"errors_by_class": summarizeErrors(r.Errors),
+			}
+			if r.Quality != nil {
+				summaries[i]["quality"] = map[string]int{"flagged": r.Quality.Flagged, "quarantined": r.Quality.Quarantined}
 			}
 		}
 		return map[string]any{"runs": summaries, "count": len(summaries)}, nil
 
+	case "collector.run_errors":
+		return queryRunErrors(args)
+
 	case "collector.list_catalog":
 		return map[string]any{"resources": catalog, "count": len(catalog)}, nil
 
@@ -283,17 +445,7 @@ func callTool(name string, args map[string]any) (any, error) {
 		runID := strVal(args["run_id"])
 		mu.RLock()
 		defer mu.RUnlock()
-		var target *collectionRun
-		if runID != "" {
-			for i := range runs {
-				if runs[i].ID == runID {
-					target = &runs[i]
-					break
-				}
-			}
-		} else if len(runs) > 0 {
-			target = &runs[len(runs)-1]
-		}
+		target := findRunLocked(runID)
 		if target == nil {
 			return nil, fmt.Errorf("no collection runs found")
 		}
@@ -309,16 +461,52 @@ func callTool(name string, args map[string]any) (any, error) {
 		}
 		return map[string]any{"run_id": target.ID, "values": values, "count": len(values)}, nil
 
+	case "collector.diff_runs":
+		return diffRuns(args)
+
 	case "collector.export_jsonld":
-		resourceID := strVal(args["resource_id"])
-		return exportJSONLD(resourceID)
+		sel, err := selectionFromArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return exportJSONLD(sel, strVal(args["format"]))
+
+	case "collector.export_table":
+		return exportTable(args)
+
+	case "collector.export_sdmx":
+		return exportSDMX(args)
+
+	case "collector.snapshot":
+		return createSnapshot(args)
+
+	case "collector.verify_snapshot":
+		return verifySnapshot(args)
+
+	case "collector.git_export":
+		return exportToRepo(args)
+
+	case "collector.git_log":
+		return repoLog(args)
+
+	case "collector.git_show":
+		return repoShow(args)
 
 	case "collector.publish":
 		targetURL := strVal(args["target_mcp_url"])
 		if targetURL == "" {
 			targetURL = "https://actors.gftd.ai/w5n8p3q6/api/mcp"
 		}
-		return publishToMCP(targetURL)
+		return publishToMCP(tc, targetURL, boolVal(args["include_flagged"]))
+
+	case "collector.quality_report":
+		return getQualityReport(args)
+
+	case "collector.list_quality_rules":
+		return listQualityRules()
+
+	case "collector.set_quality_rule":
+		return setQualityRule(args)
 
 	default:
 		return nil, fmt.Errorf("unknown tool: %s", name)
@@ -327,12 +515,13 @@ func callTool(name string, args map[string]any) (any, error) {
 
 // ---------- collection engine ----------
 
-func executeCollection(filterIDs []string, year int) collectionRun {
+func executeCollection(tc traceContext, filterIDs []string, year int) collectionRun {
 	now := time.Now().UTC()
 	run := collectionRun{
 		ID:        fmt.Sprintf("run-%d", now.UnixNano()),
 		StartedAt: now.Format(time.RFC3339),
 		Status:    "running",
+		TraceID:   tc.TraceID,
 	}
 
 	targetResources := catalog
@@ -353,12 +542,31 @@ func executeCollection(filterIDs []string, year int) collectionRun {
 
 	for _, res := range targetResources {
 		for _, reg := range regions {
-			values, err := fetchWorldBankData(res.Indicator, reg.Code, year)
+			var values []wbDataPoint
+			var err error
+			attempts := 0
+			for attempts < maxFetchAttempts {
+				attempts++
+				values, err = fetchWorldBankData(tc, res.Indicator, reg.Code, year)
+				var fe *fetchError
+				if err == nil || !errors.As(err, &fe) || !fe.retryable() {
+					break
+				}
+				if attempts < maxFetchAttempts {
+					time.Sleep(time.Duration(attempts) * 500 * time.Millisecond)
+				}
+			}
 			if err != nil {
-				run.Errors = append(run.Errors, fmt.Sprintf("%s/%s: %v", res.ID, reg.Code, err))
+				run.Errors = append(run.Errors, newRunError(res.ID, reg.Code, sourceWorldBank, attempts, err))
 				continue
 			}
 			for _, v := range values {
+				if v.lastUpdated != "" {
+					if run.SourceVersions == nil {
+						run.SourceVersions = map[string]string{}
+					}
+					run.SourceVersions[res.Indicator] = v.lastUpdated
+				}
 				run.Values = append(run.Values, collectedValue{
 					ResourceID: res.ID,
 					Region:     reg.Code,
@@ -373,6 +581,7 @@ func executeCollection(filterIDs []string, year int) collectionRun {
 		}
 	}
 
+	validateRun(&run, targetResources)
 	run.Collected = len(run.Values)
 	run.FinishedAt = time.Now().UTC().Format(time.RFC3339)
 	if len(run.Errors) > 0 && run.Collected == 0 {
@@ -382,6 +591,10 @@ func executeCollection(filterIDs []string, year int) collectionRun {
 	} else {
 		run.Status = "completed"
 	}
+	logEvent(tc, "info", "collection run finished", map[string]any{
+		"run_id": run.ID, "status": run.Status, "resources_requested": run.Resources,
+		"values_collected": run.Collected, "error_count": len(run.Errors),
+	})
 
 	mu.Lock()
 	runs = append(runs, run)
@@ -390,15 +603,20 @@ func executeCollection(filterIDs []string, year int) collectionRun {
 	}
 	mu.Unlock()
 
+	if raw, err := json.Marshal(run); err == nil {
+		_ = store.Set("run:"+run.ID, raw)
+	}
+
 	return run
 }
 
 type wbDataPoint struct {
-	year  int
-	value float64
+	year        int
+	value       float64
+	lastUpdated string
 }
 
-func fetchWorldBankData(indicator, countryCode string, targetYear int) ([]wbDataPoint, error) {
+func fetchWorldBankData(tc traceContext, indicator, countryCode string, targetYear int) (points []wbDataPoint, err error) {
 	dateRange := "2020:2024"
 	if targetYear > 0 {
 		dateRange = fmt.Sprintf("%d:%d", targetYear, targetYear)
@@ -408,36 +626,86 @@ func fetchWorldBankData(indicator, countryCode string, targetYear int) ([]wbData
 		strings.ToLower(countryCode), indicator, dateRange,
 	)
 
+	span := tc.child()
+	start := time.Now()
+	status := 0
+	defer func() {
+		fields := map[string]any{
+			"source": sourceWorldBank, "url": url, "indicator": indicator, "region": countryCode,
+			"status": status, "points": len(points), "duration_ms": time.Since(start).Milliseconds(),
+		}
+		level := "info"
+		if err != nil {
+			level, fields["error"] = "warn", err
+		}
+		logEvent(span, level, "upstream fetch", fields)
+	}()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, &fetchError{Class: errClassRequest, Err: fmt.Errorf("request: %w", err)}
+	}
+	// Allow only once the request is built, so a half-open probe is always
+	// followed by breakerRecord.
+	if err := breakerAllow(sourceWorldBank); err != nil {
+		return nil, &fetchError{Class: errClassCircuitOpen, Err: err}
+	}
+	req.Header.Set("traceparent", span.header())
 	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Get(url)
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("http: %w", err)
+		breakerRecord(sourceWorldBank, true)
+		return nil, &fetchError{Class: errClassNetwork, Err: fmt.Errorf("http: %w", err)}
 	}
 	defer resp.Body.Close()
 
+	status = resp.StatusCode
 	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512*1024))
+	breakerRecord(sourceWorldBank, resp.StatusCode >= 500)
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return nil, fmt.Errorf("http %d", resp.StatusCode)
+		return nil, &fetchError{Class: errClassHTTP, Status: resp.StatusCode, Err: fmt.Errorf("http %d", resp.StatusCode)}
 	}
 
 	// World Bank API returns [metadata, data[]] array
 	var raw []json.RawMessage
 	if err := json.Unmarshal(body, &raw); err != nil {
-		return nil, fmt.Errorf("json: %w", err)
+		return nil, &fetchError{Class: errClassParse, Err: fmt.Errorf("json: %w", err)}
 	}
 	if len(raw) < 2 {
-		return nil, fmt.Errorf("no data")
+		// Errors come back as [{"message":[{"id","key","value"}]}] with a 200.
+		if len(raw) == 1 {
+			var apiErr struct {
+				Message []struct {
+					ID    string `json:"id"`
+					Key   string `json:"key"`
+					Value string `json:"value"`
+				} `json:"message"`
+			}
+			if err := json.Unmarshal(raw[0], &apiErr); err != nil {
+				return nil, &fetchError{Class: errClassParse, Err: fmt.Errorf("parse response: %w", err)}
+			}
+			if len(apiErr.Message) > 0 {
+				m := apiErr.Message[0]
+				return nil, &fetchError{Class: errClassUpstream, Err: fmt.Errorf("api error %s: %s: %s", m.ID, m.Key, m.Value)}
+			}
+		}
+		return nil, &fetchError{Class: errClassParse, Err: fmt.Errorf("unexpected response with %d elements", len(raw))}
 	}
 
+	var meta struct {
+		LastUpdated string `json:"lastupdated"`
+	}
+	_ = json.Unmarshal(raw[0], &meta)
+
 	var entries []struct {
 		Date  string  `json:"date"`
 		Value *float64 `json:"value"`
 	}
 	if err := json.Unmarshal(raw[1], &entries); err != nil {
-		return nil, fmt.Errorf("parse entries: %w", err)
+		return nil, &fetchError{Class: errClassParse, Err: fmt.Errorf("parse entries: %w", err)}
 	}
 
-	points := make([]wbDataPoint, 0, len(entries))
+	points = make([]wbDataPoint, 0, len(entries))
 	for _, e := range entries {
 		if e.Value == nil {
 			continue
@@ -446,7 +714,10 @@ func fetchWorldBankData(indicator, countryCode string, targetYear int) ([]wbData
 		if year == 0 {
 			continue
 		}
-		points = append(points, wbDataPoint{year: year, value: *e.Value})
+		points = append(points, wbDataPoint{year: year, value: *e.Value, lastUpdated: meta.LastUpdated})
+	}
+	if len(points) == 0 {
+		return nil, &fetchError{Class: errClassNoData, Err: fmt.Errorf("no data")}
 	}
 	sort.Slice(points, func(i, j int) bool { return points[i].year > points[j].year })
 	return points, nil
@@ -454,55 +725,43 @@ func fetchWorldBankData(indicator, countryCode string, targetYear int) ([]wbData
 
 // ---------- JSON-LD export ----------
 
-func exportJSONLD(resourceID string) (any, error) {
+func exportJSONLD(sel valueSelection, format string) (any, error) {
+	if _, ok := exportFormats[format]; format != "" && !ok {
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
 	mu.RLock()
-	defer mu.RUnlock()
-	if len(runs) == 0 {
-		return nil, fmt.Errorf("no collection runs available; call collector.run first")
+	ds, err := selectValuesLocked(sel)
+	mu.RUnlock()
+	if err != nil {
+		return nil, err
 	}
-	latest := runs[len(runs)-1]
-
-	graph := make([]jsonldResource, 0)
-	for _, v := range latest.Values {
-		if resourceID != "" && v.ResourceID != resourceID {
-			continue
-		}
-		graph = append(graph, jsonldResource{
-			Context:     "https://schema.org/",
-			Type:        "Observation",
-			ID:          fmt.Sprintf("https://resources.gftd.ai/content/resource/%s/%s/%d", v.ResourceID, strings.ToLower(v.Region), v.Year),
-			Name:        fmt.Sprintf("%s - %s (%d)", v.ResourceID, v.RegionName, v.Year),
-			Description: fmt.Sprintf("Collected value for %s in %s, year %d", v.ResourceID, v.RegionName, v.Year),
-			Region:      v.RegionName,
-			Year:        v.Year,
-			Value:       v.Value,
-			Unit:        v.Unit,
-			Source:      v.Source,
-			DateCreated: v.FetchedAt,
-		})
+	g := buildCubeGraph(ds)
+	out, err := serializeGraph(g, format)
+	if err != nil {
+		return nil, err
 	}
-
-	return map[string]any{
-		"@context": "https://schema.org/",
-		"@type":    "Dataset",
-		"@id":      "https://resources.gftd.ai/content/resource/collection",
-		"name":     "GFTD Global Resource Collection",
-		"dateCreated": latest.FinishedAt,
-		"@graph":   graph,
-		"count":    len(graph),
-	}, nil
+	if format == "" || format == "jsonld" {
+		return out, nil
+	}
+	result := map[string]any{"format": format, "media_type": exportFormats[format], "triples": len(g.ntLines(""))}
+	if s, ok := out.(string); ok {
+		result["content"] = s
+	} else {
+		result["document"] = out
+	}
+	return result, nil
 }
 
 // ---------- publish to MCP ----------
 
-func publishToMCP(targetURL string) (any, error) {
+func publishToMCP(tc traceContext, targetURL string, includeFlagged bool) (any, error) {
 	mu.RLock()
 	if len(runs) == 0 {
 		mu.RUnlock()
 		return nil, fmt.Errorf("no collection runs; call collector.run first")
 	}
 	latest := runs[len(runs)-1]
-	values := latest.Values
+	values := publishableValues(latest.Values, includeFlagged)
 	mu.RUnlock()
 
 	if len(values) == 0 {
@@ -510,20 +769,37 @@ func publishToMCP(targetURL string) (any, error) {
 	}
 
 	// call global-mcp-component to refresh its data
-	result, err := callExternalMCPTool(targetURL, "global.list_resources", map[string]any{})
+	result, err := callExternalMCPTool(tc, targetURL, "global.list_resources", map[string]any{})
 	if err != nil {
-		return map[string]any{"status": "error", "detail": err.Error()}, nil
+		return map[string]any{"status": "error", "detail": err.Error(), "trace_id": tc.TraceID}, nil
 	}
 
 	return map[string]any{
 		"status":          "published",
+		"run_id":          latest.ID,
+		"trace_id":        tc.TraceID,
 		"values_count":    len(values),
 		"target_url":      targetURL,
 		"target_response": result,
 	}, nil
 }
 
-func callExternalMCPTool(mcpURL, toolName string, args map[string]any) (map[string]any, error) {
+func callExternalMCPTool(tc traceContext, mcpURL, toolName string, args map[string]any) (result map[string]any, err error) {
+	span := tc.child()
+	start := time.Now()
+	status := 0
+	defer func() {
+		fields := map[string]any{
+			"url": mcpURL, "tool": toolName, "status": status,
+			"duration_ms": time.Since(start).Milliseconds(),
+		}
+		level := "info"
+		if err != nil {
+			level, fields["error"] = "warn", err
+		}
+		logEvent(span, level, "mcp call", fields)
+	}()
+
 	reqBody := map[string]any{
 		"jsonrpc": "2.0",
 		"id":      fmt.Sprintf("collector-%d", time.Now().UnixNano()),
@@ -536,12 +812,14 @@ func callExternalMCPTool(mcpURL, toolName string, args map[string]any) (map[stri
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", span.header())
 	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	status = resp.StatusCode
 	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		return nil, fmt.Errorf("http %d", resp.StatusCode)
@@ -553,7 +831,7 @@ func callExternalMCPTool(mcpURL, toolName string, args map[string]any) (map[stri
 	if e, ok := parsed["error"].(map[string]any); ok {
 		return nil, fmt.Errorf("mcp error: %v", e["message"])
 	}
-	result, _ := parsed["result"].(map[string]any)
+	result, _ = parsed["result"].(map[string]any)
 	if result == nil {
 		return parsed, nil
 	}
@@ -596,6 +874,30 @@ func toStringSlice(v any) []string {
 	return out
 }
 
+func toIntSlice(v any) []int {
+	raw, _ := v.([]any)
+	out := make([]int, 0, len(raw))
+	for _, x := range raw {
+		if n := toInt(x); n != 0 {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func floatPtr(v any) *float64 {
+	f, ok := v.(float64)
+	if !ok {
+		return nil
+	}
+	return &f
+}
+
+func boolVal(v any) bool {
+	b, _ := v.(bool)
+	return b
+}
+
 func strVal(v any) string {
 	s, _ := v.(string)
 	return strings.TrimSpace(s)
@@ -619,7 +921,8 @@ func toInt(v any) int {
 func handleCORS(w http.ResponseWriter, r *http.Request) bool {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization,traceparent")
+	w.Header().Set("Access-Control-Expose-Headers", "X-Trace-Id,traceresponse")
 	if r.Method == http.MethodOptions {
 		w.WriteHeader(http.StatusNoContent)
 		return true
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ---------- data quality validation ----------
+
+// Quality actions. Flagged rows stay in run.Values but are excluded from
+// export and publish unless include_flagged is set; quarantined rows are moved
+// to run.Quarantined and never leave the collector.
+const (
+	qualityFlag       = "flag"
+	qualityQuarantine = "quarantine"
+)
+
+type qualityRule struct {
+	ResourceID       string   `json:"resource_id"`
+	Min              *float64 `json:"min,omitempty"`
+	Max              *float64 `json:"max,omitempty"`
+	MaxYoYChange     float64  `json:"max_yoy_change,omitempty"`
+	RequiredYears    []int    `json:"required_years,omitempty"`
+	RejectDuplicates bool     `json:"reject_duplicates"`
+	Action           string   `json:"action"`
+}
+
+type qualityIssue struct {
+	ResourceID string  `json:"resource_id"`
+	Region     string  `json:"region"`
+	Year       int     `json:"year"`
+	Value      float64 `json:"value"`
+	Check      string  `json:"check"`
+	Detail     string  `json:"detail"`
+	Action     string  `json:"action"`
+}
+
+type missingYear struct {
+	ResourceID string `json:"resource_id"`
+	Region     string `json:"region"`
+	Year       int    `json:"year"`
+}
+
+type qualityReport struct {
+	Checked     int            `json:"checked"`
+	Passed      int            `json:"passed"`
+	Flagged     int            `json:"flagged"`
+	Quarantined int            `json:"quarantined"`
+	Issues      []qualityIssue `json:"issues"`
+	Missing     []missingYear  `json:"missing_years,omitempty"`
+}
+
+// qualityRules is keyed by resource ID; defaultQualityRule covers resources
+// without an explicit entry. Guarded by mu.
+var qualityRules = map[string]qualityRule{}
+
+// defaultQualityRule derives bounds from the World Bank indicator: ".ZS"
+// indicators are percentage shares and must fall within [0, 100].
+func defaultQualityRule(res resourceDef) qualityRule {
+	lo := 0.0
+	rule := qualityRule{ResourceID: res.ID, Min: &lo, MaxYoYChange: 1.0, RejectDuplicates: true, Action: qualityFlag}
+	if u, ok := unitFor(res.Indicator); ok && u.Unit == "PERCENT" {
+		hi := 100.0
+		rule.Max = &hi
+	}
+	return rule
+}
+
+func ruleForLocked(resourceID string) qualityRule {
+	if r, ok := qualityRules[resourceID]; ok {
+		return r
+	}
+	for _, res := range catalog {
+		if res.ID == resourceID {
+			return defaultQualityRule(res)
+		}
+	}
+	return qualityRule{ResourceID: resourceID, RejectDuplicates: true, Action: qualityFlag}
+}
+
+// validateRun applies quality rules to run.Values in place, annotating flagged
+// rows, moving quarantined rows to run.Quarantined and attaching the report.
+func validateRun(run *collectionRun, targetResources []resourceDef) {
+	mu.RLock()
+	rules := make(map[string]qualityRule, len(targetResources))
+	for _, res := range targetResources {
+		rules[res.ID] = ruleForLocked(res.ID)
+	}
+	mu.RUnlock()
+
+	report := &qualityReport{Checked: len(run.Values), Issues: make([]qualityIssue, 0)}
+	issues := make([][]qualityIssue, len(run.Values))
+	addIssue := func(i int, check, detail string) {
+		v := run.Values[i]
+		action := rules[v.ResourceID].Action
+		if action != qualityQuarantine {
+			action = qualityFlag
+		}
+		issues[i] = append(issues[i], qualityIssue{
+			ResourceID: v.ResourceID, Region: v.Region, Year: v.Year, Value: v.Value,
+			Check: check, Detail: detail, Action: action,
+		})
+	}
+
+	type seriesKey struct{ resource, region string }
+	series := map[seriesKey][]int{}
+	seen := map[string]bool{}
+	for i, v := range run.Values {
+		rule := rules[v.ResourceID]
+		if math.IsNaN(v.Value) || math.IsInf(v.Value, 0) {
+			addIssue(i, "range", "value is not finite")
+		}
+		if rule.Min != nil && v.Value < *rule.Min {
+			addIssue(i, "range", fmt.Sprintf("%g below minimum %g", v.Value, *rule.Min))
+		}
+		if rule.Max != nil && v.Value > *rule.Max {
+			addIssue(i, "range", fmt.Sprintf("%g above maximum %g", v.Value, *rule.Max))
+		}
+		key := fmt.Sprintf("%s|%s|%d", v.ResourceID, v.Region, v.Year)
+		if seen[key] {
+			if rule.RejectDuplicates {
+				addIssue(i, "duplicate", "repeated resource/region/year")
+			}
+			continue
+		}
+		seen[key] = true
+		sk := seriesKey{v.ResourceID, v.Region}
+		series[sk] = append(series[sk], i)
+	}
+
+	for sk, idx := range series {
+		rule := rules[sk.resource]
+		if rule.MaxYoYChange > 0 {
+			sort.Slice(idx, func(a, b int) bool { return run.Values[idx[a]].Year < run.Values[idx[b]].Year })
+			for k := 1; k < len(idx); k++ {
+				prev, cur := run.Values[idx[k-1]], run.Values[idx[k]]
+				if cur.Year-prev.Year != 1 || prev.Value == 0 {
+					continue
+				}
+				change := math.Abs(cur.Value-prev.Value) / math.Abs(prev.Value)
+				if change > rule.MaxYoYChange {
+					addIssue(idx[k], "yoy_change", fmt.Sprintf("%.1f%% change from %d exceeds %.1f%%", change*100, prev.Year, rule.MaxYoYChange*100))
+				}
+			}
+		}
+	}
+
+	// required years are checked per region that was requested for the resource
+	for _, res := range targetResources {
+		rule := rules[res.ID]
+		for _, reg := range regions {
+			for _, y := range rule.RequiredYears {
+				if !seen[fmt.Sprintf("%s|%s|%d", res.ID, reg.Code, y)] {
+					report.Missing = append(report.Missing, missingYear{ResourceID: res.ID, Region: reg.Code, Year: y})
+				}
+			}
+		}
+	}
+
+	kept := make([]collectedValue, 0, len(run.Values))
+	for i, v := range run.Values {
+		if len(issues[i]) == 0 {
+			report.Passed++
+			kept = append(kept, v)
+			continue
+		}
+		report.Issues = append(report.Issues, issues[i]...)
+		quarantine := false
+		for _, is := range issues[i] {
+			v.QualityFlags = append(v.QualityFlags, is.Check)
+			quarantine = quarantine || is.Action == qualityQuarantine
+		}
+		if quarantine {
+			v.Quality = "quarantined"
+			report.Quarantined++
+			run.Quarantined = append(run.Quarantined, v)
+			continue
+		}
+		v.Quality = "flagged"
+		report.Flagged++
+		kept = append(kept, v)
+	}
+	run.Values = kept
+	run.Quality = report
+}
+
+// publishableValues drops flagged rows unless includeFlagged is set.
+func publishableValues(values []collectedValue, includeFlagged bool) []collectedValue {
+	if includeFlagged {
+		return values
+	}
+	out := make([]collectedValue, 0, len(values))
+	for _, v := range values {
+		if v.Quality == "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func listQualityRules() (any, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]qualityRule, 0, len(catalog))
+	for _, res := range catalog {
+		out = append(out, ruleForLocked(res.ID))
+	}
+	return map[string]any{"rules": out, "count": len(out)}, nil
+}
+
+// setQualityRule replaces the rule for a resource. Omitted bounds are cleared,
+// so callers send the full rule they want in force.
+func setQualityRule(args map[string]any) (any, error) {
+	resourceID := strVal(args["resource_id"])
+	if resourceID == "" {
+		return nil, fmt.Errorf("resource_id is required")
+	}
+	known := false
+	for _, res := range catalog {
+		known = known || res.ID == resourceID
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown resource: %s", resourceID)
+	}
+	rule := qualityRule{
+		ResourceID:       resourceID,
+		Min:              floatPtr(args["min"]),
+		Max:              floatPtr(args["max"]),
+		RequiredYears:    toIntSlice(args["required_years"]),
+		RejectDuplicates: true,
+		Action:           qualityFlag,
+	}
+	if v, ok := args["max_yoy_change"].(float64); ok {
+		if v < 0 {
+			return nil, fmt.Errorf("max_yoy_change must be >= 0")
+		}
+		rule.MaxYoYChange = v
+	}
+	if v, ok := args["reject_duplicates"].(bool); ok {
+		rule.RejectDuplicates = v
+	}
+	if action := strVal(args["action"]); action != "" {
+		if action != qualityFlag && action != qualityQuarantine {
+			return nil, fmt.Errorf("action must be %q or %q", qualityFlag, qualityQuarantine)
+		}
+		rule.Action = action
+	}
+	if rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max {
+		return nil, fmt.Errorf("min %g exceeds max %g", *rule.Min, *rule.Max)
+	}
+	mu.Lock()
+	qualityRules[resourceID] = rule
+	mu.Unlock()
+	return map[string]any{"rule": rule}, nil
+}
+
+func getQualityReport(args map[string]any) (any, error) {
+	runID := strVal(args["run_id"])
+	mu.RLock()
+	defer mu.RUnlock()
+	target := findRunLocked(runID)
+	if target == nil {
+		return nil, fmt.Errorf("no collection runs found")
+	}
+	return map[string]any{"run_id": target.ID, "quality": target.Quality, "quarantined": target.Quarantined}, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ---------- RDF graph model ----------
+
+// Every export format is serialized from an rdfGraph so Turtle, N-Triples,
+// N-Quads and the JSON-LD shapes always carry the same statements.
+
+const (
+	rdfNS         = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	rdfType       = rdfNS + "type"
+	rdfLangString = rdfNS + "langString"
+	xsdNS         = "http://www.w3.org/2001/XMLSchema#"
+	xsdString     = xsdNS + "string"
+	xsdInteger    = xsdNS + "integer"
+	xsdDecimal    = xsdNS + "decimal"
+	xsdDouble     = xsdNS + "double"
+	xsdBoolean    = xsdNS + "boolean"
+	xsdDateTime   = xsdNS + "dateTime"
+)
+
+type termKind int
+
+const (
+	termIRI termKind = iota
+	termBlank
+	termLiteral
+)
+
+type rdfTerm struct {
+	Kind     termKind
+	Value    string
+	Datatype string
+	Lang     string
+}
+
+func iriTerm(v string) rdfTerm   { return rdfTerm{Kind: termIRI, Value: v} }
+func blankTerm(v string) rdfTerm { return rdfTerm{Kind: termBlank, Value: v} }
+
+func literalTerm(v, datatype string) rdfTerm {
+	if datatype == "" {
+		datatype = xsdString
+	}
+	return rdfTerm{Kind: termLiteral, Value: v, Datatype: datatype}
+}
+
+func langTerm(v, lang string) rdfTerm {
+	return rdfTerm{Kind: termLiteral, Value: v, Datatype: rdfLangString, Lang: strings.ToLower(lang)}
+}
+
+type rdfTriple struct {
+	S, P, O rdfTerm
+}
+
+type rdfGraph struct {
+	Triples []rdfTriple
+}
+
+func (g *rdfGraph) add(s rdfTerm, p string, o rdfTerm) {
+	g.Triples = append(g.Triples, rdfTriple{S: s, P: iriTerm(p), O: o})
+}
+
+// subjects returns subjects in order of first appearance, which keeps the
+// grouped serializations in the order the graph was built.
+func (g *rdfGraph) subjects() []rdfTerm {
+	seen := map[rdfTerm]bool{}
+	out := make([]rdfTerm, 0)
+	for _, t := range g.Triples {
+		if !seen[t.S] {
+			seen[t.S] = true
+			out = append(out, t.S)
+		}
+	}
+	return out
+}
+
+// ---------- N-Triples / N-Quads ----------
+
+func (t rdfTerm) nt() string {
+	switch t.Kind {
+	case termIRI:
+		return "<" + escapeIRI(t.Value) + ">"
+	case termBlank:
+		return "_:" + t.Value
+	}
+	lit := `"` + escapeLiteral(t.Value) + `"`
+	switch {
+	case t.Lang != "":
+		return lit + "@" + t.Lang
+	case t.Datatype != "" && t.Datatype != xsdString:
+		return lit + "^^<" + escapeIRI(t.Datatype) + ">"
+	}
+	return lit
+}
+
+// ntLines renders each triple as a sorted, de-duplicated N-Triples statement,
+// optionally placed in graphName. Sorting makes the output byte-stable.
+func (g *rdfGraph) ntLines(graphName string) []string {
+	suffix := " ."
+	if graphName != "" {
+		suffix = " <" + escapeIRI(graphName) + "> ."
+	}
+	set := make(map[string]bool, len(g.Triples))
+	for _, t := range g.Triples {
+		set[t.S.nt()+" "+t.P.nt()+" "+t.O.nt()+suffix] = true
+	}
+	return sortedKeys(set)
+}
+
+func writeNTriples(g *rdfGraph) string {
+	return strings.Join(g.ntLines(""), "\n") + "\n"
+}
+
+func writeNQuads(g *rdfGraph, graphName string) string {
+	return strings.Join(g.ntLines(graphName), "\n") + "\n"
+}
+
+func escapeLiteral(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+func escapeIRI(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r <= 0x20 || strings.ContainsRune(`<>"{}|^`+"`\\", r) {
+			fmt.Fprintf(&b, `\u%04X`, r)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isAlnum(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fixtureGraph is a small Data Cube export plus statements that exercise
+// escaping, language tags and blank nodes in every serializer.
+func fixtureGraph() *rdfGraph {
+	g := buildCubeGraph(cubeDataset{
+		RunIDs:      []string{"run-1"},
+		DateCreated: "2024-05-01T00:00:00Z",
+		Values: []collectedValue{
+			{ResourceID: "crude-oil", Region: "US", RegionName: "United States", Year: 2022, Value: 0.65, FetchedAt: "2024-05-01T00:00:00Z"},
+			{ResourceID: "crude-oil", Region: "JP", RegionName: "Japan", Year: 2023, Value: 3.25, FetchedAt: "2024-05-01T00:00:00Z"},
+			{ResourceID: "wheat", Region: "CN", RegionName: "China", Year: 2022, Value: 104.1, FetchedAt: "2024-05-01T00:00:00Z"},
+		},
+	})
+	node := iriTerm(resourcesBase + "content/resource/fixture")
+	g.add(node, rdfsNS+"label", literalTerm("quote \" backslash \\ tab\t newline\n unicode é", xsdString))
+	g.add(node, rdfsNS+"label", langTerm("Rohöl", "de"))
+	g.add(node, schemaNS+"description", literalTerm("-12", xsdInteger))
+	return g
+}
+
+// parseSerialized reads output produced by serializeGraph back into triples.
+func parseSerialized(out any, format string) (rdfGraph, error) {
+	if s, ok := out.(string); ok {
+		if format == "turtle" {
+			return parseTurtle(s)
+		}
+		return parseNQuads(s)
+	}
+	doc, err := normalizeJSON(out)
+	if err != nil {
+		return rdfGraph{}, err
+	}
+	return parseJSONLD(doc)
+}
+
+func sortedTriples(g *rdfGraph) []string {
+	lines := canonicalBlanks(g).ntLines("")
+	sort.Strings(lines)
+	return lines
+}
+
+func TestSerializationRoundTrip(t *testing.T) {
+	g := fixtureGraph()
+	want := sortedTriples(g)
+	if len(exportFormats) != 7 {
+		t.Fatalf("got %d export formats, want 7", len(exportFormats))
+	}
+	for _, format := range sortedKeys(exportFormats) {
+		t.Run(format, func(t *testing.T) {
+			out, err := serializeGraph(g, format)
+			if err != nil {
+				t.Fatalf("serialize: %v", err)
+			}
+			parsed, err := parseSerialized(out, format)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			got := sortedTriples(&parsed)
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				missing, unexpected := diffGraphs(g, &parsed)
+				t.Fatalf("%d triples, want %d\nmissing:\n%s\nunexpected:\n%s",
+					len(got), len(want), strings.Join(missing, "\n"), strings.Join(unexpected, "\n"))
+			}
+		})
+	}
+}
+
+// goldenGraph is small enough to write its serializations out by hand, so a
+// mistake shared by a writer and its reader cannot pass unnoticed.
+func goldenGraph() *rdfGraph {
+	g := &rdfGraph{}
+	obs := iriTerm("http://example.org/obs1")
+	g.add(obs, rdfType, iriTerm("http://purl.org/linked-data/cube#Observation"))
+	g.add(obs, "http://example.org/value", literalTerm("0.65", xsdDecimal))
+	g.add(obs, rdfsNS+"label", literalTerm("quote \" tab\t", xsdString))
+	g.add(obs, rdfsNS+"label", langTerm("Rohöl", "DE"))
+	g.add(obs, "http://example.org/unit", blankTerm("b0"))
+	g.add(blankTerm("b0"), "http://example.org/code", literalTerm("PERCENT", ""))
+	return g
+}
+
+const goldenNQuads = `<http://example.org/obs1> <http://example.org/unit> _:b0 <http://example.org/ds> .
+<http://example.org/obs1> <http://example.org/value> "0.65"^^<http://www.w3.org/2001/XMLSchema#decimal> <http://example.org/ds> .
+<http://example.org/obs1> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://purl.org/linked-data/cube#Observation> <http://example.org/ds> .
+<http://example.org/obs1> <http://www.w3.org/2000/01/rdf-schema#label> "Rohöl"@de <http://example.org/ds> .
+<http://example.org/obs1> <http://www.w3.org/2000/01/rdf-schema#label> "quote \" tab\t" <http://example.org/ds> .
+_:b0 <http://example.org/code> "PERCENT" <http://example.org/ds> .
+`
+
+const goldenTurtle = `@prefix ex: <http://example.org/> .
+@prefix qb: <http://purl.org/linked-data/cube#> .
+@prefix rdfs: <http://www.w3.org/2000/01/rdf-schema#> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+
+ex:obs1 a qb:Observation ;
+    ex:value "0.65"^^xsd:decimal ;
+    rdfs:label "quote \" tab\t", "Rohöl"@de ;
+    ex:unit _:b0 .
+
+_:b0 ex:code "PERCENT" .
+`
+
+func TestGoldenSerializations(t *testing.T) {
+	g := goldenGraph()
+	prefixes := map[string]string{
+		"ex":   "http://example.org/",
+		"qb":   "http://purl.org/linked-data/cube#",
+		"rdfs": rdfsNS,
+		"xsd":  xsdNS,
+	}
+	if got := writeNQuads(g, "http://example.org/ds"); got != goldenNQuads {
+		t.Errorf("N-Quads:\n%s\nwant:\n%s", got, goldenNQuads)
+	}
+	if got := writeTurtle(g, prefixes); got != goldenTurtle {
+		t.Errorf("Turtle:\n%s\nwant:\n%s", got, goldenTurtle)
+	}
+
+	want := strings.Join(sortedTriples(g), "\n")
+	nq, err := parseNQuads(goldenNQuads)
+	if err != nil {
+		t.Fatalf("parse N-Quads: %v", err)
+	}
+	if got := strings.Join(sortedTriples(&nq), "\n"); got != want {
+		t.Errorf("parsed N-Quads:\n%s\nwant:\n%s", got, want)
+	}
+	ttl, err := parseTurtle(goldenTurtle)
+	if err != nil {
+		t.Fatalf("parse Turtle: %v", err)
+	}
+	if got := strings.Join(sortedTriples(&ttl), "\n"); got != want {
+		t.Errorf("parsed Turtle:\n%s\nwant:\n%s", got, want)
+	}
+}
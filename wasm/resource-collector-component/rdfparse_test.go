@@ -0,0 +1,296 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ---------- N-Triples / N-Quads reader ----------
+
+// parseNQuads reads N-Triples or N-Quads. Graph labels are accepted and
+// dropped; callers compare the default-graph projection.
+func parseNQuads(src string) (rdfGraph, error) {
+	var g rdfGraph
+	for n, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lx := &rdfLexer{src: line}
+		terms := make([]rdfTerm, 0, 4)
+		for {
+			lx.skipSpace()
+			if lx.peek() == '.' {
+				lx.pos++
+				break
+			}
+			t, err := lx.ntTerm()
+			if err != nil {
+				return g, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			terms = append(terms, t)
+		}
+		if len(terms) != 3 && len(terms) != 4 {
+			return g, fmt.Errorf("line %d: expected 3 or 4 terms, got %d", n+1, len(terms))
+		}
+		if terms[1].Kind != termIRI {
+			return g, fmt.Errorf("line %d: predicate must be an IRI", n+1)
+		}
+		g.Triples = append(g.Triples, rdfTriple{S: terms[0], P: terms[1], O: terms[2]})
+	}
+	return g, nil
+}
+
+// ---------- lexer shared by the N-Triples and Turtle readers ----------
+
+type rdfLexer struct {
+	src string
+	pos int
+}
+
+func (l *rdfLexer) peek() byte {
+	if l.pos >= len(l.src) {
+		return 0
+	}
+	return l.src[l.pos]
+}
+
+func (l *rdfLexer) skipSpace() {
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case ' ', '\t', '\r', '\n':
+			l.pos++
+		case '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *rdfLexer) ntTerm() (rdfTerm, error) {
+	switch l.peek() {
+	case '<':
+		v, err := l.iriRef()
+		return iriTerm(v), err
+	case '_':
+		return l.blankNode()
+	case '"':
+		return l.literal(func() (string, error) {
+			if l.peek() != '<' {
+				return "", fmt.Errorf("expected datatype IRI at %d", l.pos)
+			}
+			return l.iriRef()
+		})
+	case 0:
+		return rdfTerm{}, fmt.Errorf("unexpected end of statement")
+	}
+	return rdfTerm{}, fmt.Errorf("unexpected %q at %d", l.peek(), l.pos)
+}
+
+func (l *rdfLexer) iriRef() (string, error) {
+	end := strings.IndexByte(l.src[l.pos:], '>')
+	if end < 0 {
+		return "", fmt.Errorf("unterminated IRI at %d", l.pos)
+	}
+	raw := l.src[l.pos+1 : l.pos+end]
+	l.pos += end + 1
+	return unescape(raw)
+}
+
+func (l *rdfLexer) blankNode() (rdfTerm, error) {
+	if !strings.HasPrefix(l.src[l.pos:], "_:") {
+		return rdfTerm{}, fmt.Errorf("malformed blank node at %d", l.pos)
+	}
+	start := l.pos + 2
+	end := start
+	for end < len(l.src) && isNameChar(l.src[end]) {
+		end++
+	}
+	for end > start && l.src[end-1] == '.' {
+		end--
+	}
+	if end == start {
+		return rdfTerm{}, fmt.Errorf("empty blank node label at %d", l.pos)
+	}
+	l.pos = end
+	return blankTerm(l.src[start:end]), nil
+}
+
+// literal reads a quoted string with an optional language tag or datatype;
+// datatype reads whatever IRI form the surrounding syntax allows.
+func (l *rdfLexer) literal(datatype func() (string, error)) (rdfTerm, error) {
+	l.pos++
+	var b strings.Builder
+	for {
+		if l.pos >= len(l.src) {
+			return rdfTerm{}, fmt.Errorf("unterminated literal")
+		}
+		c := l.src[l.pos]
+		if c == '"' {
+			l.pos++
+			break
+		}
+		if c == '\\' {
+			n, r, err := unescapeAt(l.src, l.pos)
+			if err != nil {
+				return rdfTerm{}, err
+			}
+			b.WriteRune(r)
+			l.pos += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		b.WriteRune(r)
+		l.pos += size
+	}
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "^^"):
+		l.pos += 2
+		dt, err := datatype()
+		if err != nil {
+			return rdfTerm{}, err
+		}
+		return literalTerm(b.String(), dt), nil
+	case l.peek() == '@':
+		start := l.pos + 1
+		end := start
+		for end < len(l.src) && (isAlnum(l.src[end]) || l.src[end] == '-') {
+			end++
+		}
+		l.pos = end
+		return langTerm(b.String(), l.src[start:end]), nil
+	}
+	return literalTerm(b.String(), xsdString), nil
+}
+
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		n, r, err := unescapeAt(s, i)
+		if err != nil {
+			return "", err
+		}
+		b.WriteRune(r)
+		i += n
+	}
+	return b.String(), nil
+}
+
+func unescapeAt(s string, i int) (int, rune, error) {
+	if i+1 >= len(s) {
+		return 0, 0, fmt.Errorf("dangling escape")
+	}
+	switch s[i+1] {
+	case 't':
+		return 2, '\t', nil
+	case 'n':
+		return 2, '\n', nil
+	case 'r':
+		return 2, '\r', nil
+	case 'b':
+		return 2, '\b', nil
+	case 'f':
+		return 2, '\f', nil
+	case '"', '\'', '\\':
+		return 2, rune(s[i+1]), nil
+	case 'u', 'U':
+		width := 4
+		if s[i+1] == 'U' {
+			width = 8
+		}
+		if i+2+width > len(s) {
+			return 0, 0, fmt.Errorf("short unicode escape")
+		}
+		var r rune
+		if _, err := fmt.Sscanf(s[i+2:i+2+width], "%x", &r); err != nil {
+			return 0, 0, fmt.Errorf("bad unicode escape: %w", err)
+		}
+		return 2 + width, r, nil
+	}
+	return 0, 0, fmt.Errorf("unknown escape \\%c", s[i+1])
+}
+
+func isNameChar(c byte) bool {
+	return isAlnum(c) || c == '_' || c == '-' || c == '.' || c >= 0x80
+}
+
+// ---------- comparison ----------
+
+// diffGraphs compares two graphs as sets of default-graph triples and
+// returns the statements only in want and only in got. Blank node labels are
+// not significant.
+func diffGraphs(want, got *rdfGraph) (missing, unexpected []string) {
+	a, b := map[string]bool{}, map[string]bool{}
+	for _, l := range canonicalBlanks(want).ntLines("") {
+		a[l] = true
+	}
+	for _, l := range canonicalBlanks(got).ntLines("") {
+		b[l] = true
+	}
+	for l := range a {
+		if !b[l] {
+			missing = append(missing, l)
+		}
+	}
+	for l := range b {
+		if !a[l] {
+			unexpected = append(unexpected, l)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	return missing, unexpected
+}
+
+// canonicalBlanks relabels blank nodes by a digest of their outgoing
+// statements so graphs that differ only in labels compare equal. Blank nodes
+// with identical outgoing statements are treated as interchangeable, which
+// holds for the structures the exports generate.
+func canonicalBlanks(g *rdfGraph) *rdfGraph {
+	sigs := map[rdfTerm][]string{}
+	for _, t := range g.Triples {
+		if t.S.Kind != termBlank {
+			continue
+		}
+		o := t.O.nt()
+		if t.O.Kind == termBlank {
+			o = "_"
+		}
+		sigs[t.S] = append(sigs[t.S], t.P.nt()+" "+o)
+	}
+	labels := map[rdfTerm]rdfTerm{}
+	for b, lines := range sigs {
+		sort.Strings(lines)
+		sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+		labels[b] = blankTerm("c" + hex.EncodeToString(sum[:8]))
+	}
+	relabel := func(t rdfTerm) rdfTerm {
+		if t.Kind != termBlank {
+			return t
+		}
+		if l, ok := labels[t]; ok {
+			return l
+		}
+		return t
+	}
+	out := &rdfGraph{Triples: make([]rdfTriple, len(g.Triples))}
+	for i, t := range g.Triples {
+		out.Triples[i] = rdfTriple{S: relabel(t.S), P: t.P, O: relabel(t.O)}
+	}
+	return out
+}
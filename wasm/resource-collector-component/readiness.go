@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ---------- readiness ----------
+
+// defaultMaxRunAge allows one missed scheduler cadence (every 6h) before the
+// collector reports itself stale. Override with COLLECTOR_MAX_RUN_AGE.
+const defaultMaxRunAge = 12 * time.Hour
+
+var processStartedAt = time.Now().UTC()
+
+// readinessCheck is one /readyz probe. A skipped check could not be performed
+// in this build; it is reported but does not affect the status.
+type readinessCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := []readinessCheck{
+		checkKeyValue(),
+		checkCatalog(),
+		checkLastRun(time.Now().UTC(), maxRunAge()),
+		checkUpstreams(),
+	}
+	status, code := "ready", http.StatusOK
+	for _, c := range checks {
+		if !c.OK && !c.Skipped {
+			status, code = "not_ready", http.StatusServiceUnavailable
+			break
+		}
+	}
+	writeJSON(w, code, map[string]any{"status": status, "service": "resource-collector-component", "checks": checks})
+}
+
+func maxRunAge() time.Duration {
+	if raw := strings.TrimSpace(os.Getenv("COLLECTOR_MAX_RUN_AGE")); raw != "" {
+		if d, err := time.ParseDuration(raw); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultMaxRunAge
+}
+
+// checkKeyValue round-trips a probe through the store. The in-memory store
+// cannot fail, and world.wit imports no wasi:keyvalue, so with it the check
+// is skipped rather than reported as passing.
+func checkKeyValue() readinessCheck {
+	c := readinessCheck{Name: "keyvalue"}
+	if _, ok := store.(*memoryStore); ok {
+		c.Skipped = true
+		c.Detail = "not checked: no wasi:keyvalue import; runs are kept in process memory"
+		return c
+	}
+	probe := []byte(time.Now().UTC().Format(time.RFC3339Nano))
+	if err := store.Set("readyz:probe", probe); err != nil {
+		c.Detail = fmt.Sprintf("set: %v", err)
+		return c
+	}
+	got, ok, err := store.Get("readyz:probe")
+	switch {
+	case err != nil:
+		c.Detail = fmt.Sprintf("get: %v", err)
+	case !ok || string(got) != string(probe):
+		c.Detail = "probe value not read back"
+	default:
+		c.OK = true
+	}
+	return c
+}
+
+func checkCatalog() readinessCheck {
+	c := readinessCheck{Name: "catalog"}
+	if len(catalog) == 0 {
+		c.Detail = "catalog is empty"
+		return c
+	}
+	seen := map[string]bool{}
+	for _, res := range catalog {
+		switch {
+		case res.ID == "":
+			c.Detail = "resource with empty id"
+		case seen[res.ID]:
+			c.Detail = fmt.Sprintf("duplicate resource id %q", res.ID)
+		case res.Indicator == "":
+			c.Detail = fmt.Sprintf("resource %q has no indicator", res.ID)
+		case res.Unit == "":
+			c.Detail = fmt.Sprintf("resource %q has no unit", res.ID)
+		}
+		if c.Detail != "" {
+			return c
+		}
+		seen[res.ID] = true
+	}
+	c.OK = true
+	c.Detail = fmt.Sprintf("%d resources", len(catalog))
+	return c
+}
+
+// checkLastRun fails when the newest finished run is older than maxAge. Before
+// the first run, the process start time stands in so a fresh instance is ready
+// long enough for the scheduler to reach it.
+func checkLastRun(now time.Time, maxAge time.Duration) readinessCheck {
+	c := readinessCheck{Name: "last_run"}
+	mu.RLock()
+	var last *collectionRun
+	if len(runs) > 0 {
+		last = &runs[len(runs)-1]
+	}
+	mu.RUnlock()
+
+	if last == nil {
+		age := now.Sub(processStartedAt)
+		c.OK = age <= maxAge
+		c.Detail = fmt.Sprintf("no runs yet; up %s (max %s)", age.Round(time.Second), maxAge)
+		return c
+	}
+	finished, err := time.Parse(time.RFC3339, last.FinishedAt)
+	if err != nil {
+		c.Detail = fmt.Sprintf("run %s has invalid finished_at %q", last.ID, last.FinishedAt)
+		return c
+	}
+	age := now.Sub(finished)
+	c.OK = age <= maxAge
+	c.Detail = fmt.Sprintf("run %s (%s) finished %s ago (max %s)", last.ID, last.Status, age.Round(time.Second), maxAge)
+	return c
+}
+
+func checkUpstreams() readinessCheck {
+	c := readinessCheck{Name: "upstreams"}
+	snapshot := breakerSnapshot()
+	open := make([]string, 0)
+	for _, b := range snapshot {
+		if b.State == "open" {
+			open = append(open, b.Source)
+		}
+	}
+	c.OK = len(open) < len(snapshot)
+	if len(open) > 0 {
+		c.Detail = fmt.Sprintf("circuit open: %s", strings.Join(open, ", "))
+	} else {
+		c.Detail = fmt.Sprintf("%d sources available", len(snapshot))
+	}
+	return c
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ---------- run error records ----------
+
+// Error classes recorded on collection runs.
+const (
+	errClassNetwork     = "network"
+	errClassHTTP        = "http"
+	errClassParse       = "parse"
+	errClassNoData      = "no-data"
+	errClassCircuitOpen = "circuit-open"
+	errClassUpstream    = "upstream"
+	errClassRequest     = "request"
+)
+
+// maxFetchAttempts bounds retries of transient (network, 429 and 5xx) failures
+// for a single resource/region pair.
+const maxFetchAttempts = 3
+
+type runError struct {
+	ResourceID string `json:"resource_id"`
+	Region     string `json:"region"`
+	Source     string `json:"source"`
+	Class      string `json:"class"`
+	HTTPStatus int    `json:"http_status,omitempty"`
+	Attempts   int    `json:"attempts"`
+	Message    string `json:"message"`
+	OccurredAt string `json:"occurred_at"`
+}
+
+// fetchError is returned by upstream fetchers so the collection loop can
+// classify failures without parsing error strings.
+type fetchError struct {
+	Class  string
+	Status int
+	Err    error
+}
+
+func (e *fetchError) Error() string {
+	if e.Class == errClassHTTP {
+		return fmt.Sprintf("http %d", e.Status)
+	}
+	return e.Err.Error()
+}
+
+func (e *fetchError) Unwrap() error { return e.Err }
+
+// retryable reports whether another attempt might succeed.
+func (e *fetchError) retryable() bool {
+	switch e.Class {
+	case errClassNetwork:
+		return true
+	case errClassHTTP:
+		return e.Status == 429 || e.Status >= 500
+	}
+	return false
+}
+
+func newRunError(resourceID, region, source string, attempts int, err error) runError {
+	rec := runError{
+		ResourceID: resourceID,
+		Region:     region,
+		Source:     source,
+		Class:      errClassNetwork,
+		Attempts:   attempts,
+		Message:    err.Error(),
+		OccurredAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	var fe *fetchError
+	if errors.As(err, &fe) {
+		rec.Class = fe.Class
+		rec.HTTPStatus = fe.Status
+	}
+	return rec
+}
+
+func summarizeErrors(errs []runError) map[string]int {
+	summary := map[string]int{}
+	for _, e := range errs {
+		summary[e.Class]++
+	}
+	return summary
+}
+
+// findRunLocked returns the run with runID, or the latest run when runID is
+// empty. Callers must hold mu.
+func findRunLocked(runID string) *collectionRun {
+	if runID == "" {
+		if len(runs) == 0 {
+			return nil
+		}
+		return &runs[len(runs)-1]
+	}
+	for i := range runs {
+		if runs[i].ID == runID {
+			return &runs[i]
+		}
+	}
+	return nil
+}
+
+func queryRunErrors(args map[string]any) (any, error) {
+	runID := strVal(args["run_id"])
+	class := strVal(args["class"])
+	resourceID := strVal(args["resource_id"])
+	region := strVal(args["region"])
+	source := strVal(args["source"])
+
+	mu.RLock()
+	defer mu.RUnlock()
+	target := findRunLocked(runID)
+	if target == nil {
+		if runID != "" {
+			return nil, fmt.Errorf("run not found: %s", runID)
+		}
+		return nil, fmt.Errorf("no collection runs found")
+	}
+
+	out := make([]runError, 0)
+	for _, e := range target.Errors {
+		if class != "" && e.Class != class {
+			continue
+		}
+		if resourceID != "" && e.ResourceID != resourceID {
+			continue
+		}
+		if region != "" && e.Region != region {
+			continue
+		}
+		if source != "" && e.Source != source {
+			continue
+		}
+		out = append(out, e)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].ResourceID != out[j].ResourceID {
+			return out[i].ResourceID < out[j].ResourceID
+		}
+		return out[i].Region < out[j].Region
+	})
+	return map[string]any{
+		"run_id":   target.ID,
+		"status":   target.Status,
+		"errors":   out,
+		"count":    len(out),
+		"by_class": summarizeErrors(out),
+	}, nil
+}
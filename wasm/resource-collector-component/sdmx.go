@@ -0,0 +1,543 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- SDMX export ----------
+
+// The SDMX export generates a data structure definition from the catalog and
+// region list, builds the data set against it, validates the data set with
+// that same structure, and then serializes both as SDMX-JSON 1.0 or SDMX-ML
+// 2.1 (GenericData plus a Structure message).
+const (
+	sdmxAgency     = "GFTD"
+	sdmxDSDID      = "GFTD_RESOURCES"
+	sdmxVersion    = "1.0"
+	sdmxConceptsID = "CS_GFTD_RESOURCES"
+
+	sdmxJSONMediaType      = "application/vnd.sdmx.data+json;version=1.0.0"
+	sdmxMLMediaType        = "application/vnd.sdmx.genericdata+xml;version=2.1"
+	sdmxStructureMediaType = "application/vnd.sdmx.structure+xml;version=2.1"
+
+	sdmxMessageNS   = "http://www.sdmx.org/resources/sdmxml/schemas/v2_1/message"
+	sdmxGenericNS   = "http://www.sdmx.org/resources/sdmxml/schemas/v2_1/data/generic"
+	sdmxCommonNS    = "http://www.sdmx.org/resources/sdmxml/schemas/v2_1/common"
+	sdmxStructureNS = "http://www.sdmx.org/resources/sdmxml/schemas/v2_1/structure"
+)
+
+type sdmxCode struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// sdmxComponent is a dimension or attribute of the DSD. Codelist is empty for
+// the time dimension, which uses a text format instead.
+type sdmxComponent struct {
+	ID       string
+	Name     string
+	Codelist string
+	Codes    []sdmxCode
+}
+
+type sdmxDSD struct {
+	Dimensions    []sdmxComponent // series-level dimensions, in key order
+	TimeDimension sdmxComponent
+	Attributes    []sdmxComponent // series-level, mandatory
+	Measure       sdmxComponent
+}
+
+type sdmxObs struct {
+	Period string
+	Value  float64
+}
+
+type sdmxSeries struct {
+	Key        []string // one code per DSD dimension
+	Attributes []string // one code per DSD attribute
+	Obs        []sdmxObs
+}
+
+// buildSDMXStructure derives the DSD. Codelists cover the whole catalog and
+// region list so structures stay stable across runs.
+func buildSDMXStructure() sdmxDSD {
+	resources := make([]sdmxCode, 0, len(catalog))
+	units := map[string]string{}
+	for _, r := range catalog {
+		resources = append(resources, sdmxCode{ID: r.ID, Name: r.Name})
+		code, name := sdmxUnit(r.ID, r.Unit)
+		units[code] = name
+	}
+	areas := make([]sdmxCode, 0, len(regions))
+	for _, r := range regions {
+		areas = append(areas, sdmxCode{ID: r.Code, Name: r.Name})
+	}
+	unitCodes := make([]sdmxCode, 0, len(units))
+	for _, id := range sortedKeys(units) {
+		unitCodes = append(unitCodes, sdmxCode{ID: id, Name: units[id]})
+	}
+	return sdmxDSD{
+		Dimensions: []sdmxComponent{
+			{ID: "RESOURCE", Name: "Resource", Codelist: "CL_RESOURCE", Codes: resources},
+			{ID: "REF_AREA", Name: "Reference area", Codelist: "CL_AREA", Codes: areas},
+		},
+		TimeDimension: sdmxComponent{ID: "TIME_PERIOD", Name: "Time period"},
+		Attributes: []sdmxComponent{
+			{ID: "UNIT_MEASURE", Name: "Unit of measure", Codelist: "CL_UNIT_MEASURE", Codes: unitCodes},
+			{ID: "SOURCE", Name: "Source", Codelist: "CL_SOURCE", Codes: []sdmxCode{{ID: sdmxCodeID("World Bank API"), Name: "World Bank API"}}},
+		},
+		Measure: sdmxComponent{ID: "OBS_VALUE", Name: "Observation value"},
+	}
+}
+
+// sdmxUnit names the unit of a resource's indicator by its QUDT unit and
+// power-of-ten multiplier, e.g. PERCENT or CCY_USD_E9, and returns the code with
+// its code list name. Resources without a known unit fall back to the label.
+func sdmxUnit(resourceID, label string) (string, string) {
+	u, ok := resourceUnit(resourceID)
+	if !ok {
+		return sdmxCodeID(label), label
+	}
+	if u.Mult == 0 {
+		return sdmxCodeID(u.Unit), u.Name
+	}
+	return sdmxCodeID(fmt.Sprintf("%s_E%d", u.Unit, u.Mult)), u.Name
+}
+
+// sdmxCodeID maps free text onto the SDMX IDType character set.
+func sdmxCodeID(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(s) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return strings.Trim(b.String(), "_")
+}
+
+// buildSDMXSeries groups values into series keyed by RESOURCE and REF_AREA.
+func buildSDMXSeries(values []collectedValue) []sdmxSeries {
+	index := map[string]int{}
+	out := make([]sdmxSeries, 0)
+	for _, v := range values {
+		key := v.ResourceID + "." + v.Region
+		i, ok := index[key]
+		if !ok {
+			i = len(out)
+			index[key] = i
+			source := v.Source
+			if source == "" {
+				source = "World Bank API"
+			}
+			unit, _ := sdmxUnit(v.ResourceID, v.Unit)
+			out = append(out, sdmxSeries{
+				Key:        []string{v.ResourceID, v.Region},
+				Attributes: []string{unit, sdmxCodeID(source)},
+			})
+		}
+		out[i].Obs = append(out[i].Obs, sdmxObs{Period: strconv.Itoa(v.Year), Value: v.Value})
+	}
+	sort.Slice(out, func(i, j int) bool { return strings.Join(out[i].Key, ".") < strings.Join(out[j].Key, ".") })
+	for i := range out {
+		obs := out[i].Obs
+		sort.SliceStable(obs, func(a, b int) bool { return obs[a].Period < obs[b].Period })
+	}
+	return out
+}
+
+// validateSDMX checks the data set against the DSD it will be published with:
+// key and attribute codes must be in their codelists, periods must be
+// four-digit years, values finite and each series/period unique.
+func validateSDMX(dsd sdmxDSD, series []sdmxSeries) []string {
+	errs := make([]string, 0)
+	inList := func(c sdmxComponent, code string) bool {
+		for _, x := range c.Codes {
+			if x.ID == code {
+				return true
+			}
+		}
+		return false
+	}
+	seenKeys := map[string]bool{}
+	for _, s := range series {
+		key := strings.Join(s.Key, ".")
+		if len(s.Key) != len(dsd.Dimensions) {
+			errs = append(errs, fmt.Sprintf("series %s: key has %d values, DSD has %d dimensions", key, len(s.Key), len(dsd.Dimensions)))
+			continue
+		}
+		if seenKeys[key] {
+			errs = append(errs, fmt.Sprintf("series %s: duplicate series key", key))
+		}
+		seenKeys[key] = true
+		for i, d := range dsd.Dimensions {
+			if !inList(d, s.Key[i]) {
+				errs = append(errs, fmt.Sprintf("series %s: %s code %q not in %s", key, d.ID, s.Key[i], d.Codelist))
+			}
+		}
+		for i, a := range dsd.Attributes {
+			if i >= len(s.Attributes) || s.Attributes[i] == "" {
+				errs = append(errs, fmt.Sprintf("series %s: mandatory attribute %s missing", key, a.ID))
+			} else if !inList(a, s.Attributes[i]) {
+				errs = append(errs, fmt.Sprintf("series %s: %s code %q not in %s", key, a.ID, s.Attributes[i], a.Codelist))
+			}
+		}
+		periods := map[string]bool{}
+		for _, o := range s.Obs {
+			if y, err := strconv.Atoi(o.Period); err != nil || len(o.Period) != 4 || y < 1000 {
+				errs = append(errs, fmt.Sprintf("series %s: %s %q is not a year", key, dsd.TimeDimension.ID, o.Period))
+			}
+			if periods[o.Period] {
+				errs = append(errs, fmt.Sprintf("series %s: duplicate observation for %s", key, o.Period))
+			}
+			periods[o.Period] = true
+			if math.IsNaN(o.Value) || math.IsInf(o.Value, 0) {
+				errs = append(errs, fmt.Sprintf("series %s/%s: %s is not finite", key, o.Period, dsd.Measure.ID))
+			}
+		}
+	}
+	return errs
+}
+
+func sdmxMessageID() string {
+	return fmt.Sprintf("GFTD-%d", time.Now().UnixNano())
+}
+
+// sdmxJSON renders an SDMX-JSON 1.0 data message. Series keys and
+// observation keys are positional indexes into the structure's value lists,
+// which are restricted to the codes actually used.
+func sdmxJSON(dsd sdmxDSD, series []sdmxSeries, prepared string) map[string]any {
+	used := func(c sdmxComponent, pick func(sdmxSeries) string) ([]sdmxCode, map[string]int) {
+		codes := make([]sdmxCode, 0)
+		pos := map[string]int{}
+		for _, code := range c.Codes {
+			for _, s := range series {
+				if pick(s) == code.ID {
+					pos[code.ID] = len(codes)
+					codes = append(codes, code)
+					break
+				}
+			}
+		}
+		return codes, pos
+	}
+
+	dimStructs := make([]any, 0, len(dsd.Dimensions))
+	dimPos := make([]map[string]int, len(dsd.Dimensions))
+	for i, d := range dsd.Dimensions {
+		i := i
+		codes, pos := used(d, func(s sdmxSeries) string { return s.Key[i] })
+		dimPos[i] = pos
+		dimStructs = append(dimStructs, map[string]any{"id": d.ID, "name": d.Name, "keyPosition": i, "values": codes})
+	}
+	attrStructs := make([]any, 0, len(dsd.Attributes))
+	attrPos := make([]map[string]int, len(dsd.Attributes))
+	for i, a := range dsd.Attributes {
+		i := i
+		codes, pos := used(a, func(s sdmxSeries) string { return s.Attributes[i] })
+		attrPos[i] = pos
+		attrStructs = append(attrStructs, map[string]any{"id": a.ID, "name": a.Name, "values": codes})
+	}
+	periodSet := map[string]bool{}
+	for _, s := range series {
+		for _, o := range s.Obs {
+			periodSet[o.Period] = true
+		}
+	}
+	periods := sortedKeys(periodSet)
+	periodPos := map[string]int{}
+	periodCodes := make([]sdmxCode, len(periods))
+	for i, p := range periods {
+		periodPos[p] = i
+		periodCodes[i] = sdmxCode{ID: p, Name: p}
+	}
+
+	seriesOut := map[string]any{}
+	for _, s := range series {
+		keyParts := make([]string, len(s.Key))
+		for i, code := range s.Key {
+			keyParts[i] = strconv.Itoa(dimPos[i][code])
+		}
+		attrs := make([]any, len(s.Attributes))
+		for i, code := range s.Attributes {
+			attrs[i] = attrPos[i][code]
+		}
+		obs := map[string]any{}
+		for _, o := range s.Obs {
+			obs[strconv.Itoa(periodPos[o.Period])] = []any{o.Value}
+		}
+		seriesOut[strings.Join(keyParts, ":")] = map[string]any{"attributes": attrs, "observations": obs}
+	}
+
+	return map[string]any{
+		"header": map[string]any{
+			"id":       sdmxMessageID(),
+			"test":     false,
+			"prepared": prepared,
+			"sender":   map[string]any{"id": sdmxAgency, "name": "GFTD"},
+		},
+		"dataSets": []any{map[string]any{"action": "Information", "series": seriesOut}},
+		"structure": map[string]any{
+			"links": []any{map[string]any{
+				"rel":  "datastructure",
+				"urn":  fmt.Sprintf("urn:sdmx:org.sdmx.infomodel.datastructure.DataStructure=%s:%s(%s)", sdmxAgency, sdmxDSDID, sdmxVersion),
+				"href": dsdIRI,
+			}},
+			"name": "GFTD Global Resource Collection",
+			"dimensions": map[string]any{
+				"dataSet": []any{},
+				"series":  dimStructs,
+				"observation": []any{map[string]any{
+					"id": dsd.TimeDimension.ID, "name": dsd.TimeDimension.Name, "keyPosition": len(dsd.Dimensions),
+					"role": "time", "values": periodCodes,
+				}},
+			},
+			"attributes": map[string]any{"dataSet": []any{}, "series": attrStructs, "observation": []any{}},
+			"measures":   map[string]any{"observation": []any{map[string]any{"id": dsd.Measure.ID, "name": dsd.Measure.Name}}},
+		},
+	}
+}
+
+// xmlWriter is a minimal indenting writer for the SDMX-ML messages.
+type xmlWriter struct {
+	b     strings.Builder
+	depth int
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func (w *xmlWriter) open(tag string, attrs ...string) {
+	w.line("<" + tag + xmlAttrs(attrs) + ">")
+	w.depth++
+}
+
+func (w *xmlWriter) close(tag string) {
+	w.depth--
+	w.line("</" + tag + ">")
+}
+
+func (w *xmlWriter) empty(tag string, attrs ...string) {
+	w.line("<" + tag + xmlAttrs(attrs) + "/>")
+}
+
+func (w *xmlWriter) text(tag, text string, attrs ...string) {
+	w.line("<" + tag + xmlAttrs(attrs) + ">" + xmlEscape(text) + "</" + tag + ">")
+}
+
+func (w *xmlWriter) line(s string) {
+	w.b.WriteString(strings.Repeat("  ", w.depth))
+	w.b.WriteString(s)
+	w.b.WriteByte('\n')
+}
+
+// xmlAttrs renders name/value pairs.
+func xmlAttrs(kv []string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&b, ` %s="%s"`, kv[i], xmlEscape(kv[i+1]))
+	}
+	return b.String()
+}
+
+func sdmxHeader(w *xmlWriter, prepared string, withStructure bool) {
+	w.open("message:Header")
+	w.text("message:ID", sdmxMessageID())
+	w.text("message:Test", "false")
+	w.text("message:Prepared", prepared)
+	w.empty("message:Sender", "id", sdmxAgency)
+	if withStructure {
+		w.open("message:Structure", "structureID", sdmxDSDID, "dimensionAtObservation", "TIME_PERIOD")
+		w.open("common:Structure")
+		w.empty("Ref", "agencyID", sdmxAgency, "id", sdmxDSDID, "version", sdmxVersion)
+		w.close("common:Structure")
+		w.close("message:Structure")
+	}
+	w.close("message:Header")
+}
+
+// sdmxMLData renders an SDMX-ML 2.1 GenericData message.
+func sdmxMLData(dsd sdmxDSD, series []sdmxSeries, prepared string) string {
+	w := &xmlWriter{}
+	w.line(`<?xml version="1.0" encoding="UTF-8"?>`)
+	w.open("message:GenericData", "xmlns:message", sdmxMessageNS, "xmlns:generic", sdmxGenericNS, "xmlns:common", sdmxCommonNS)
+	sdmxHeader(w, prepared, true)
+	w.open("message:DataSet", "structureRef", sdmxDSDID, "action", "Information")
+	for _, s := range series {
+		w.open("generic:Series")
+		w.open("generic:SeriesKey")
+		for i, d := range dsd.Dimensions {
+			w.empty("generic:Value", "id", d.ID, "value", s.Key[i])
+		}
+		w.close("generic:SeriesKey")
+		w.open("generic:Attributes")
+		for i, a := range dsd.Attributes {
+			w.empty("generic:Value", "id", a.ID, "value", s.Attributes[i])
+		}
+		w.close("generic:Attributes")
+		for _, o := range s.Obs {
+			w.open("generic:Obs")
+			w.empty("generic:ObsDimension", "value", o.Period)
+			w.empty("generic:ObsValue", "value", formatDecimal(o.Value))
+			w.close("generic:Obs")
+		}
+		w.close("generic:Series")
+	}
+	w.close("message:DataSet")
+	w.close("message:GenericData")
+	return w.b.String()
+}
+
+// sdmxMLStructure renders the DSD with its codelists and concept scheme as an
+// SDMX-ML 2.1 Structure message.
+func sdmxMLStructure(dsd sdmxDSD, prepared string) string {
+	w := &xmlWriter{}
+	name := func(text string) { w.text("common:Name", text, "xml:lang", "en") }
+	conceptRef := func(id string) {
+		w.open("structure:ConceptIdentity")
+		w.empty("Ref", "id", id, "maintainableParentID", sdmxConceptsID, "maintainableParentVersion", sdmxVersion, "agencyID", sdmxAgency, "package", "conceptscheme", "class", "Concept")
+		w.close("structure:ConceptIdentity")
+	}
+	enumeration := func(codelist string) {
+		w.open("structure:LocalRepresentation")
+		w.open("structure:Enumeration")
+		w.empty("Ref", "id", codelist, "version", sdmxVersion, "agencyID", sdmxAgency, "package", "codelist", "class", "Codelist")
+		w.close("structure:Enumeration")
+		w.close("structure:LocalRepresentation")
+	}
+	coded := append(append([]sdmxComponent{}, dsd.Dimensions...), dsd.Attributes...)
+
+	w.line(`<?xml version="1.0" encoding="UTF-8"?>`)
+	w.open("message:Structure", "xmlns:message", sdmxMessageNS, "xmlns:structure", sdmxStructureNS, "xmlns:common", sdmxCommonNS)
+	sdmxHeader(w, prepared, false)
+	w.open("message:Structures")
+
+	w.open("structure:Codelists")
+	for _, c := range coded {
+		w.open("structure:Codelist", "id", c.Codelist, "agencyID", sdmxAgency, "version", sdmxVersion)
+		name(c.Name)
+		for _, code := range c.Codes {
+			w.open("structure:Code", "id", code.ID)
+			name(code.Name)
+			w.close("structure:Code")
+		}
+		w.close("structure:Codelist")
+	}
+	w.close("structure:Codelists")
+
+	w.open("structure:Concepts")
+	w.open("structure:ConceptScheme", "id", sdmxConceptsID, "agencyID", sdmxAgency, "version", sdmxVersion)
+	name("GFTD resource collection concepts")
+	for _, c := range append(append(coded, dsd.TimeDimension), dsd.Measure) {
+		w.open("structure:Concept", "id", c.ID)
+		name(c.Name)
+		w.close("structure:Concept")
+	}
+	w.close("structure:ConceptScheme")
+	w.close("structure:Concepts")
+
+	w.open("structure:DataStructures")
+	w.open("structure:DataStructure", "id", sdmxDSDID, "agencyID", sdmxAgency, "version", sdmxVersion)
+	name("GFTD Global Resource Collection")
+	w.open("structure:DataStructureComponents")
+	w.open("structure:DimensionList", "id", "DimensionDescriptor")
+	for i, d := range dsd.Dimensions {
+		w.open("structure:Dimension", "id", d.ID, "position", strconv.Itoa(i+1))
+		conceptRef(d.ID)
+		enumeration(d.Codelist)
+		w.close("structure:Dimension")
+	}
+	w.open("structure:TimeDimension", "id", dsd.TimeDimension.ID, "position", strconv.Itoa(len(dsd.Dimensions)+1))
+	conceptRef(dsd.TimeDimension.ID)
+	w.open("structure:LocalRepresentation")
+	w.empty("structure:TextFormat", "textType", "ObservationalTimePeriod")
+	w.close("structure:LocalRepresentation")
+	w.close("structure:TimeDimension")
+	w.close("structure:DimensionList")
+	w.open("structure:AttributeList", "id", "AttributeDescriptor")
+	for _, a := range dsd.Attributes {
+		w.open("structure:Attribute", "id", a.ID, "assignmentStatus", "Mandatory")
+		conceptRef(a.ID)
+		enumeration(a.Codelist)
+		w.open("structure:AttributeRelationship")
+		for _, d := range dsd.Dimensions {
+			w.open("structure:Dimension")
+			w.empty("Ref", "id", d.ID)
+			w.close("structure:Dimension")
+		}
+		w.close("structure:AttributeRelationship")
+		w.close("structure:Attribute")
+	}
+	w.close("structure:AttributeList")
+	w.open("structure:MeasureList", "id", "MeasureDescriptor")
+	w.open("structure:PrimaryMeasure", "id", dsd.Measure.ID)
+	conceptRef(dsd.Measure.ID)
+	w.close("structure:PrimaryMeasure")
+	w.close("structure:MeasureList")
+	w.close("structure:DataStructureComponents")
+	w.close("structure:DataStructure")
+	w.close("structure:DataStructures")
+
+	w.close("message:Structures")
+	w.close("message:Structure")
+	return w.b.String()
+}
+
+func exportSDMX(args map[string]any) (any, error) {
+	format := strings.ToLower(strVal(args["format"]))
+	if format == "" {
+		format = "sdmx-json"
+	}
+	if format != "sdmx-json" && format != "sdmx-ml" {
+		return nil, fmt.Errorf("format must be sdmx-json or sdmx-ml")
+	}
+	sel, err := selectionFromArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	mu.RLock()
+	ds, err := selectValuesLocked(sel)
+	mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	dsd := buildSDMXStructure()
+	series := buildSDMXSeries(ds.Values)
+	errs := validateSDMX(dsd, series)
+	validation := map[string]any{"valid": len(errs) == 0, "errors": errs, "series": len(series), "observations": len(ds.Values)}
+	if len(errs) > 0 {
+		// never emit a message that contradicts its own structure
+		return map[string]any{"format": format, "run_ids": ds.RunIDs, "validation": validation}, nil
+	}
+	prepared := time.Now().UTC().Format(time.RFC3339)
+
+	if format == "sdmx-ml" {
+		return map[string]any{
+			"format":               format,
+			"media_type":           sdmxMLMediaType,
+			"content":              sdmxMLData(dsd, series, prepared),
+			"structure":            sdmxMLStructure(dsd, prepared),
+			"structure_media_type": sdmxStructureMediaType,
+			"run_ids":              ds.RunIDs,
+			"validation":           validation,
+		}, nil
+	}
+	return map[string]any{
+		"format":     format,
+		"media_type": sdmxJSONMediaType,
+		"document":   sdmxJSON(dsd, series, prepared),
+		"run_ids":    ds.RunIDs,
+		"validation": validation,
+	}, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ---------- value selection ----------
+
+// valueSelection picks the collected values an export is built from: one run
+// (latest by default) or, in "latest" mode, the newest value for each
+// resource/region/year across all retained runs.
+type valueSelection struct {
+	RunID          string
+	Mode           string
+	ResourceIDs    []string
+	RegionIDs      []string
+	YearFrom       int
+	YearTo         int
+	IncludeFlagged bool
+}
+
+const (
+	selectModeRun    = "run"
+	selectModeLatest = "latest"
+)
+
+// selectionSchema holds the input schema properties shared by every export tool.
+var selectionSchema = map[string]any{
+	"run_id":          map[string]any{"type": "string", "description": "Run to export (default: latest run)"},
+	"mode":            map[string]any{"type": "string", "enum": []string{selectModeRun, selectModeLatest}, "description": "run: a single run; latest: newest value per resource/region/year across all runs"},
+	"resource_id":     map[string]any{"type": "string", "description": "Single resource filter (kept for compatibility with resource_ids)"},
+	"resource_ids":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+	"region_ids":      map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "ISO 3166-1 alpha-3 codes"},
+	"year_from":       map[string]any{"type": "integer"},
+	"year_to":         map[string]any{"type": "integer"},
+	"include_flagged": map[string]any{"type": "boolean", "description": "Include rows flagged by quality validation (default: false); in latest mode a flagged or quarantined newest value drops its key instead of falling back to an older run"},
+}
+
+// withSelection returns a copy of selectionSchema merged with extra.
+func withSelection(extra map[string]any) map[string]any {
+	props := make(map[string]any, len(selectionSchema)+len(extra))
+	for k, v := range selectionSchema {
+		props[k] = v
+	}
+	for k, v := range extra {
+		props[k] = v
+	}
+	return props
+}
+
+func selectionFromArgs(args map[string]any) (valueSelection, error) {
+	sel := valueSelection{
+		RunID:          strVal(args["run_id"]),
+		Mode:           strVal(args["mode"]),
+		ResourceIDs:    toStringSlice(args["resource_ids"]),
+		RegionIDs:      toStringSlice(args["region_ids"]),
+		YearFrom:       toInt(args["year_from"]),
+		YearTo:         toInt(args["year_to"]),
+		IncludeFlagged: boolVal(args["include_flagged"]),
+	}
+	if id := strVal(args["resource_id"]); id != "" {
+		sel.ResourceIDs = append(sel.ResourceIDs, id)
+	}
+	switch sel.Mode {
+	case "":
+		sel.Mode = selectModeRun
+	case selectModeRun, selectModeLatest:
+	default:
+		return sel, fmt.Errorf("mode must be %q or %q", selectModeRun, selectModeLatest)
+	}
+	if sel.Mode == selectModeLatest && sel.RunID != "" {
+		return sel, fmt.Errorf("run_id cannot be combined with mode %q", selectModeLatest)
+	}
+	if sel.YearFrom > 0 && sel.YearTo > 0 && sel.YearFrom > sel.YearTo {
+		return sel, fmt.Errorf("year_from %d is after year_to %d", sel.YearFrom, sel.YearTo)
+	}
+	return sel, nil
+}
+
+func (sel valueSelection) matches(v collectedValue) bool {
+	if len(sel.ResourceIDs) > 0 && !containsFold(sel.ResourceIDs, v.ResourceID) {
+		return false
+	}
+	if len(sel.RegionIDs) > 0 && !containsFold(sel.RegionIDs, v.Region) {
+		return false
+	}
+	if sel.YearFrom > 0 && v.Year < sel.YearFrom {
+		return false
+	}
+	if sel.YearTo > 0 && v.Year > sel.YearTo {
+		return false
+	}
+	return true
+}
+
+// selectValuesLocked applies sel to the retained runs and describes the result
+// as a cube dataset. Callers must hold mu.
+func selectValuesLocked(sel valueSelection) (cubeDataset, error) {
+	if len(runs) == 0 {
+		return cubeDataset{}, fmt.Errorf("no collection runs available; call collector.run first")
+	}
+	if sel.Mode != selectModeLatest {
+		run := findRunLocked(sel.RunID)
+		if run == nil {
+			return cubeDataset{}, fmt.Errorf("run not found: %s", sel.RunID)
+		}
+		ds := cubeDataset{RunIDs: []string{run.ID}, DateCreated: run.FinishedAt, Values: make([]collectedValue, 0)}
+		for _, v := range publishableValues(run.Values, sel.IncludeFlagged) {
+			if sel.matches(v) {
+				ds.Values = append(ds.Values, v)
+			}
+		}
+		return ds, nil
+	}
+
+	// The newest run decides each key before quality filtering: a value that
+	// run flagged or quarantined drops the key rather than falling back to an
+	// older value.
+	ds := cubeDataset{DateCreated: runs[len(runs)-1].FinishedAt, Values: make([]collectedValue, 0)}
+	seen := map[valueKey]bool{}
+	for i := len(runs) - 1; i >= 0; i-- {
+		used := false
+		for _, v := range runs[i].Values {
+			k := valueKey{v.ResourceID, v.Region, v.Year}
+			if seen[k] || !sel.matches(v) {
+				continue
+			}
+			seen[k] = true
+			if v.Quality != "" && !sel.IncludeFlagged {
+				continue
+			}
+			used = true
+			ds.Values = append(ds.Values, v)
+		}
+		for _, v := range runs[i].Quarantined {
+			if sel.matches(v) {
+				seen[valueKey{v.ResourceID, v.Region, v.Year}] = true
+			}
+		}
+		if used {
+			ds.RunIDs = append(ds.RunIDs, runs[i].ID)
+		}
+	}
+	return ds, nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, x := range list {
+		if strings.EqualFold(x, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ---------- signed snapshots ----------
+
+// A snapshot is the publishable artifact for the resources repository: the
+// selected values as canonical JSON-LD and CSV, plus a manifest carrying the
+// SHA-256 of each file. The manifest is signed with Ed25519 and its own hash
+// is the snapshot ID, so the same selection over the same runs always yields
+// the same bundle.
+const (
+	snapshotFormat       = "gftd-resources-snapshot/1"
+	snapshotManifestPath = "manifest.json"
+	snapshotJSONLD       = "data.jsonld"
+	snapshotCSV          = "data.csv"
+	collectorVersion     = "0.1.0"
+	worldBankAPI         = "https://api.worldbank.org/v2"
+)
+
+type snapshotFile struct {
+	Path      string `json:"path"`
+	MediaType string `json:"media_type"`
+	Bytes     int    `json:"bytes"`
+	SHA256    string `json:"sha256"`
+}
+
+type snapshotSource struct {
+	ID      string `json:"id"`
+	API     string `json:"api"`
+	Version string `json:"version"`
+	// Indicators maps each World Bank indicator used to the lastupdated
+	// date reported by the API ("unknown" if the run did not record it).
+	Indicators map[string]string `json:"indicators"`
+}
+
+type snapshotManifest struct {
+	Format    string           `json:"format"`
+	Generator string           `json:"generator"`
+	Dataset   string           `json:"dataset"`
+	RunIDs    []string         `json:"run_ids"`
+	Created   string           `json:"created"`
+	Selection map[string]any   `json:"selection"`
+	Sources   []snapshotSource `json:"sources"`
+	Files     []snapshotFile   `json:"files"`
+}
+
+type snapshotSignature struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"`
+	Value     string `json:"value"`
+}
+
+// snapshotBundle is what collector.snapshot returns and collector.verify_snapshot
+// accepts. Files holds the exact bytes that were hashed; Manifest is the
+// parsed form of files["manifest.json"] for convenience only.
+type snapshotBundle struct {
+	SnapshotID string            `json:"snapshot_id"`
+	Manifest   *snapshotManifest `json:"manifest,omitempty"`
+	Files      map[string]string `json:"files"`
+	Signature  snapshotSignature `json:"signature"`
+	Ephemeral  bool              `json:"ephemeral_key,omitempty"`
+}
+
+var (
+	signingOnce     sync.Once
+	signingKey      ed25519.PrivateKey
+	signingErr      error
+	ephemeralOnce   sync.Once
+	ephemeralKey    ed25519.PrivateKey
+	ephemeralErr    error
+	errNoSigningKey = fmt.Errorf("COLLECTOR_SIGNING_KEY is not set; configure a signing key or pass allow_ephemeral")
+)
+
+// configuredSigningKey reads COLLECTOR_SIGNING_KEY, a base64 Ed25519 seed
+// (32 bytes) or private key (64 bytes).
+func configuredSigningKey() (ed25519.PrivateKey, error) {
+	signingOnce.Do(func() {
+		raw := strings.TrimSpace(os.Getenv("COLLECTOR_SIGNING_KEY"))
+		if raw == "" {
+			signingErr = errNoSigningKey
+			return
+		}
+		b, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			signingErr = fmt.Errorf("COLLECTOR_SIGNING_KEY: %w", err)
+			return
+		}
+		switch len(b) {
+		case ed25519.SeedSize:
+			signingKey = ed25519.NewKeyFromSeed(b)
+		case ed25519.PrivateKeySize:
+			signingKey = ed25519.PrivateKey(b)
+		default:
+			signingErr = fmt.Errorf("COLLECTOR_SIGNING_KEY: want %d or %d bytes, got %d", ed25519.SeedSize, ed25519.PrivateKeySize, len(b))
+		}
+	})
+	return signingKey, signingErr
+}
+
+// loadSigningKey returns the configured key. Only when none is configured and
+// allowEphemeral is set does it fall back to a key generated for the life of
+// the process; such snapshots are marked ephemeral_key and cannot be verified
+// as trusted after a restart.
+func loadSigningKey(allowEphemeral bool) (ed25519.PrivateKey, bool, error) {
+	key, err := configuredSigningKey()
+	if err != errNoSigningKey || !allowEphemeral {
+		return key, false, err
+	}
+	ephemeralOnce.Do(func() {
+		_, ephemeralKey, ephemeralErr = ed25519.GenerateKey(rand.Reader)
+	})
+	return ephemeralKey, true, ephemeralErr
+}
+
+// keyID is the first 8 bytes of the SHA-256 of the public key, in hex.
+func keyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalJSON renders v with sorted object keys, no insignificant
+// whitespace and no HTML escaping, followed by a newline.
+func canonicalJSON(v any) ([]byte, error) {
+	norm, err := normalizeJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(norm); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// selectionManifest records the selection in the manifest, omitting defaults.
+func selectionManifest(sel valueSelection) map[string]any {
+	out := map[string]any{"mode": sel.Mode, "include_flagged": sel.IncludeFlagged}
+	if len(sel.ResourceIDs) > 0 {
+		ids := append([]string(nil), sel.ResourceIDs...)
+		sort.Strings(ids)
+		out["resource_ids"] = ids
+	}
+	if len(sel.RegionIDs) > 0 {
+		ids := make([]string, len(sel.RegionIDs))
+		for i, id := range sel.RegionIDs {
+			ids[i] = strings.ToUpper(id)
+		}
+		sort.Strings(ids)
+		out["region_ids"] = ids
+	}
+	if sel.YearFrom > 0 {
+		out["year_from"] = sel.YearFrom
+	}
+	if sel.YearTo > 0 {
+		out["year_to"] = sel.YearTo
+	}
+	return out
+}
+
+// snapshotSourcesLocked lists the indicator versions behind ds. Runs in
+// ds.RunIDs are newest first, so the first version seen wins. Callers must
+// hold mu.
+func snapshotSourcesLocked(ds cubeDataset) []snapshotSource {
+	used := map[string]bool{}
+	for _, v := range ds.Values {
+		used[v.ResourceID] = true
+	}
+	indicators := map[string]string{}
+	for _, r := range catalog {
+		if used[r.ID] {
+			indicators[r.Indicator] = ""
+		}
+	}
+	for _, id := range ds.RunIDs {
+		run := findRunLocked(id)
+		if run == nil {
+			continue
+		}
+		for ind, ver := range run.SourceVersions {
+			if cur, ok := indicators[ind]; ok && cur == "" {
+				indicators[ind] = ver
+			}
+		}
+	}
+	for ind, ver := range indicators {
+		if ver == "" {
+			indicators[ind] = "unknown"
+		}
+	}
+	return []snapshotSource{{ID: sourceWorldBank, API: worldBankAPI, Version: "v2", Indicators: indicators}}
+}
+
+func createSnapshot(args map[string]any) (any, error) {
+	sel, err := selectionFromArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	bundle, err := buildSnapshot(sel, boolVal(args["allow_ephemeral"]))
+	if err != nil {
+		return nil, err
+	}
+	if stored, err := json.Marshal(bundle); err == nil {
+		_ = store.Set("snapshot:"+bundle.SnapshotID, stored)
+	}
+	return bundle, nil
+}
+
+// buildSnapshot selects the values, renders the bundle files and signs the
+// manifest.
+func buildSnapshot(sel valueSelection, allowEphemeral bool) (snapshotBundle, error) {
+	key, ephemeral, err := loadSigningKey(allowEphemeral)
+	if err != nil {
+		return snapshotBundle{}, err
+	}
+
+	mu.RLock()
+	ds, err := selectValuesLocked(sel)
+	var sources []snapshotSource
+	if err == nil {
+		sources = snapshotSourcesLocked(ds)
+	}
+	mu.RUnlock()
+	if err != nil {
+		return snapshotBundle{}, err
+	}
+	if len(ds.Values) == 0 {
+		return snapshotBundle{}, fmt.Errorf("selection matched no values")
+	}
+
+	doc, err := serializeGraph(buildCubeGraph(ds), "jsonld")
+	if err != nil {
+		return snapshotBundle{}, err
+	}
+	jsonld, err := canonicalJSON(doc)
+	if err != nil {
+		return snapshotBundle{}, err
+	}
+	table, _, err := renderTable(ds, tableOptions{Format: "csv", Layout: layoutLong, Columns: tableColumns})
+	if err != nil {
+		return snapshotBundle{}, err
+	}
+
+	files := map[string]string{snapshotJSONLD: string(jsonld), snapshotCSV: table}
+	manifest := snapshotManifest{
+		Format:    snapshotFormat,
+		Generator: "resource-collector-component/" + collectorVersion,
+		Dataset:   datasetIRI,
+		RunIDs:    ds.RunIDs,
+		Created:   ds.DateCreated,
+		Selection: selectionManifest(sel),
+		Sources:   sources,
+		Files: []snapshotFile{
+			{Path: snapshotCSV, MediaType: "text/csv; charset=utf-8", Bytes: len(table), SHA256: sha256Hex([]byte(table))},
+			{Path: snapshotJSONLD, MediaType: exportFormats["jsonld"], Bytes: len(jsonld), SHA256: sha256Hex(jsonld)},
+		},
+	}
+	raw, err := canonicalJSON(manifest)
+	if err != nil {
+		return snapshotBundle{}, err
+	}
+	files[snapshotManifestPath] = string(raw)
+
+	pub := key.Public().(ed25519.PublicKey)
+	bundle := snapshotBundle{
+		SnapshotID: "sha256:" + sha256Hex(raw),
+		Manifest:   &manifest,
+		Files:      files,
+		Signature: snapshotSignature{
+			Algorithm: "Ed25519",
+			KeyID:     keyID(pub),
+			PublicKey: base64.StdEncoding.EncodeToString(pub),
+			Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(key, raw)),
+		},
+		Ephemeral: ephemeral,
+	}
+	return bundle, nil
+}
+
+// verifySnapshot checks a bundle passed inline or one previously created by
+// this collector, looked up by snapshot_id. The signer is trusted when it is
+// the pinned public_key or the configured signing key; ok requires a valid
+// signature by a trusted signer, since anyone can re-sign a bundle with a key
+// of their own.
+func verifySnapshot(args map[string]any) (any, error) {
+	var bundle snapshotBundle
+	if raw, ok := args["bundle"]; ok && raw != nil {
+		b, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &bundle); err != nil {
+			return nil, fmt.Errorf("bundle: %w", err)
+		}
+	} else if id := strVal(args["snapshot_id"]); id != "" {
+		raw, ok, err := store.Get("snapshot:" + id)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("snapshot not found: %s", id)
+		}
+		if err := json.Unmarshal(raw, &bundle); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("bundle or snapshot_id is required")
+	}
+
+	var problems []string
+	manifestRaw, ok := bundle.Files[snapshotManifestPath]
+	if !ok {
+		return map[string]any{"ok": false, "problems": []string{"missing " + snapshotManifestPath}}, nil
+	}
+	if id := "sha256:" + sha256Hex([]byte(manifestRaw)); id != bundle.SnapshotID {
+		problems = append(problems, fmt.Sprintf("snapshot_id %s does not match manifest hash %s", bundle.SnapshotID, id))
+	}
+
+	var manifest snapshotManifest
+	if err := json.Unmarshal([]byte(manifestRaw), &manifest); err != nil {
+		problems = append(problems, "manifest: "+err.Error())
+	}
+	if manifest.Format != snapshotFormat {
+		problems = append(problems, fmt.Sprintf("unsupported manifest format %q", manifest.Format))
+	}
+	fileChecks := make([]map[string]any, 0, len(manifest.Files))
+	listed := map[string]bool{snapshotManifestPath: true}
+	for _, f := range manifest.Files {
+		listed[f.Path] = true
+		check := map[string]any{"path": f.Path, "ok": false}
+		content, ok := bundle.Files[f.Path]
+		switch {
+		case !ok:
+			check["error"] = "missing"
+		case len(content) != f.Bytes:
+			check["error"] = fmt.Sprintf("size %d, manifest says %d", len(content), f.Bytes)
+		case sha256Hex([]byte(content)) != f.SHA256:
+			check["error"] = "sha256 mismatch"
+		default:
+			check["ok"] = true
+		}
+		if check["ok"] == false {
+			problems = append(problems, fmt.Sprintf("%s: %s", f.Path, check["error"]))
+		}
+		fileChecks = append(fileChecks, check)
+	}
+	for _, path := range sortedKeys(bundle.Files) {
+		if !listed[path] {
+			problems = append(problems, path+": not listed in manifest")
+		}
+	}
+
+	sig := map[string]any{"algorithm": bundle.Signature.Algorithm, "key_id": bundle.Signature.KeyID, "valid": false, "trusted": false}
+	pinned := strVal(args["public_key"])
+	pubB64 := bundle.Signature.PublicKey
+	if pinned != "" {
+		pubB64 = pinned
+	}
+	pub, err := base64.StdEncoding.DecodeString(pubB64)
+	sigBytes, sigErr := base64.StdEncoding.DecodeString(bundle.Signature.Value)
+	switch {
+	case bundle.Signature.Algorithm != "Ed25519":
+		problems = append(problems, fmt.Sprintf("unsupported signature algorithm %q", bundle.Signature.Algorithm))
+	case err != nil || len(pub) != ed25519.PublicKeySize:
+		problems = append(problems, "invalid public key")
+	case sigErr != nil:
+		problems = append(problems, "invalid signature encoding")
+	case !ed25519.Verify(ed25519.PublicKey(pub), []byte(manifestRaw), sigBytes):
+		problems = append(problems, "signature does not verify")
+	default:
+		sig["valid"] = true
+		sig["key_id"] = keyID(pub)
+	}
+
+	trusted, trustedBy := false, ""
+	if pinned != "" {
+		bundleKey, _ := base64.StdEncoding.DecodeString(bundle.Signature.PublicKey)
+		if bundle.Signature.PublicKey != "" && !bytes.Equal(bundleKey, pub) {
+			problems = append(problems, "bundle public key does not match the pinned public_key")
+		} else {
+			trusted, trustedBy = true, "pinned"
+		}
+	} else if key, err := configuredSigningKey(); err == nil && bytes.Equal(pub, key.Public().(ed25519.PublicKey)) {
+		trusted, trustedBy = true, "configured"
+	}
+	if sig["valid"] == true {
+		sig["trusted"] = trusted
+		if trusted {
+			sig["trusted_by"] = trustedBy
+		} else if pinned == "" {
+			problems = append(problems, "signer is not trusted: pass public_key or configure COLLECTOR_SIGNING_KEY")
+		}
+	}
+
+	if problems == nil {
+		problems = []string{}
+	}
+	return map[string]any{
+		"ok":          len(problems) == 0,
+		"snapshot_id": bundle.SnapshotID,
+		"run_ids":     manifest.RunIDs,
+		"files":       fileChecks,
+		"signature":   sig,
+		"problems":    problems,
+	}, nil
+}
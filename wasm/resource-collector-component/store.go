@@ -0,0 +1,40 @@
+package main
+
+import (
+	"sync"
+)
+
+// ---------- keyvalue store ----------
+
+// keyValueStore is the subset of wasi:keyvalue/store the collector relies on.
+// Runs are persisted under "run:{id}" so they survive beyond the in-memory
+// ring buffer once a Redis-backed implementation is linked.
+type keyValueStore interface {
+	Get(key string) ([]byte, bool, error)
+	Set(key string, value []byte) error
+}
+
+type memoryStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{data: map[string][]byte{}}
+}
+
+func (s *memoryStore) Get(key string) ([]byte, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.data[key]
+	return v, ok, nil
+}
+
+func (s *memoryStore) Set(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = append([]byte(nil), value...)
+	return nil
+}
+
+var store keyValueStore = newMemoryStore()
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------- tabular export ----------
+
+const (
+	layoutLong = "long"
+	layoutWide = "wide"
+)
+
+// tableColumns are the columns available in the long layout, in default order.
+// The wide layout accepts the non-measure subset as row identifiers.
+var tableColumns = []string{"resource_id", "resource_name", "region", "region_name", "year", "value", "unit", "source", "fetched_at", "quality", "quality_flags"}
+
+var (
+	defaultLongColumns = []string{"resource_id", "region", "region_name", "year", "value", "unit", "source"}
+	defaultWideColumns = []string{"resource_id", "region", "region_name", "unit"}
+)
+
+type tableOptions struct {
+	Format   string
+	Layout   string
+	Columns  []string
+	Metadata bool
+}
+
+func tableOptionsFromArgs(args map[string]any) (tableOptions, error) {
+	opts := tableOptions{
+		Format:   strings.ToLower(strVal(args["format"])),
+		Layout:   strings.ToLower(strVal(args["layout"])),
+		Columns:  toStringSlice(args["columns"]),
+		Metadata: boolVal(args["metadata"]),
+	}
+	if opts.Format == "" {
+		opts.Format = "csv"
+	}
+	if opts.Format != "csv" && opts.Format != "tsv" {
+		return opts, fmt.Errorf("format must be csv or tsv")
+	}
+	if opts.Layout == "" {
+		opts.Layout = layoutLong
+	}
+	if opts.Layout != layoutLong && opts.Layout != layoutWide {
+		return opts, fmt.Errorf("layout must be %q or %q", layoutLong, layoutWide)
+	}
+	if len(opts.Columns) == 0 {
+		opts.Columns = defaultLongColumns
+		if opts.Layout == layoutWide {
+			opts.Columns = defaultWideColumns
+		}
+	}
+	for _, c := range opts.Columns {
+		known := false
+		for _, k := range tableColumns {
+			known = known || c == k
+		}
+		if !known {
+			return opts, fmt.Errorf("unknown column %q (want one of %s)", c, strings.Join(tableColumns, ", "))
+		}
+		if opts.Layout == layoutWide && (c == "year" || c == "value" || c == "fetched_at" || c == "quality" || c == "quality_flags") {
+			return opts, fmt.Errorf("column %q is not a row identifier in the wide layout", c)
+		}
+	}
+	return opts, nil
+}
+
+func (o tableOptions) mediaType() string {
+	if o.Format == "tsv" {
+		return "text/tab-separated-values; charset=utf-8"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+func tableCell(v collectedValue, column string, names map[string]string) string {
+	switch column {
+	case "resource_id":
+		return v.ResourceID
+	case "resource_name":
+		return names[v.ResourceID]
+	case "region":
+		return v.Region
+	case "region_name":
+		return v.RegionName
+	case "year":
+		return strconv.Itoa(v.Year)
+	case "value":
+		return formatDecimal(v.Value)
+	case "unit":
+		return v.Unit
+	case "source":
+		return v.Source
+	case "fetched_at":
+		return v.FetchedAt
+	case "quality":
+		return v.Quality
+	case "quality_flags":
+		return strings.Join(v.QualityFlags, ";")
+	}
+	return ""
+}
+
+// renderTable writes ds as CSV/TSV and returns the content and data row count.
+// Metadata lines start with '#' so most tools can be told to skip them.
+func renderTable(ds cubeDataset, opts tableOptions) (string, int, error) {
+	names := map[string]string{}
+	for _, r := range catalog {
+		names[r.ID] = r.Name
+	}
+	values := append([]collectedValue(nil), ds.Values...)
+	sort.SliceStable(values, func(i, j int) bool {
+		a, b := values[i], values[j]
+		if a.ResourceID != b.ResourceID {
+			return a.ResourceID < b.ResourceID
+		}
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		return a.Year < b.Year
+	})
+
+	var rows [][]string
+	header := append([]string(nil), opts.Columns...)
+	if opts.Layout == layoutLong {
+		for _, v := range values {
+			row := make([]string, len(opts.Columns))
+			for i, c := range opts.Columns {
+				row[i] = tableCell(v, c, names)
+			}
+			rows = append(rows, row)
+		}
+	} else {
+		yearSet := map[int]bool{}
+		type series struct {
+			first  collectedValue
+			byYear map[int]float64
+		}
+		order := make([]string, 0)
+		bySeries := map[string]*series{}
+		for _, v := range values {
+			yearSet[v.Year] = true
+			key := v.ResourceID + "|" + v.Region
+			s, ok := bySeries[key]
+			if !ok {
+				s = &series{first: v, byYear: map[int]float64{}}
+				bySeries[key] = s
+				order = append(order, key)
+			}
+			if _, dup := s.byYear[v.Year]; !dup {
+				s.byYear[v.Year] = v.Value
+			}
+		}
+		years := make([]int, 0, len(yearSet))
+		for y := range yearSet {
+			years = append(years, y)
+		}
+		sort.Ints(years)
+		for _, y := range years {
+			header = append(header, strconv.Itoa(y))
+		}
+		for _, key := range order {
+			s := bySeries[key]
+			row := make([]string, 0, len(header))
+			for _, c := range opts.Columns {
+				row = append(row, tableCell(s.first, c, names))
+			}
+			for _, y := range years {
+				if v, ok := s.byYear[y]; ok {
+					row = append(row, formatDecimal(v))
+				} else {
+					row = append(row, "")
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	var buf bytes.Buffer
+	if opts.Metadata {
+		fmt.Fprintf(&buf, "# dataset: GFTD Global Resource Collection\n")
+		fmt.Fprintf(&buf, "# runs: %s\n", strings.Join(ds.RunIDs, " "))
+		fmt.Fprintf(&buf, "# run_finished_at: %s\n", ds.DateCreated)
+		fmt.Fprintf(&buf, "# generated_at: %s\n", time.Now().UTC().Format(time.RFC3339))
+		fmt.Fprintf(&buf, "# source: World Bank API (%s)\n", worldBankIRI)
+		fmt.Fprintf(&buf, "# layout: %s\n", opts.Layout)
+		fmt.Fprintf(&buf, "# rows: %d\n", len(rows))
+	}
+	cw := csv.NewWriter(&buf)
+	if opts.Format == "tsv" {
+		cw.Comma = '\t'
+	}
+	if err := cw.Write(header); err != nil {
+		return "", 0, err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return "", 0, err
+	}
+	return buf.String(), len(rows), nil
+}
+
+func tableFilename(ds cubeDataset, sel valueSelection, opts tableOptions) string {
+	name := "latest"
+	if sel.Mode != selectModeLatest && len(ds.RunIDs) == 1 {
+		name = ds.RunIDs[0]
+	}
+	if opts.Layout == layoutWide {
+		name += "-wide"
+	}
+	return fmt.Sprintf("gftd-resources-%s.%s", name, opts.Format)
+}
+
+func exportTable(args map[string]any) (any, error) {
+	sel, err := selectionFromArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := tableOptionsFromArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	mu.RLock()
+	ds, err := selectValuesLocked(sel)
+	mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	content, rows, err := renderTable(ds, opts)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"format":     opts.Format,
+		"layout":     opts.Layout,
+		"media_type": opts.mediaType(),
+		"filename":   tableFilename(ds, sel, opts),
+		"run_ids":    ds.RunIDs,
+		"rows":       rows,
+		"content":    content,
+	}, nil
+}
+
+// handleExportDownload serves GET /export/{run_id}.{csv|tsv}. "latest" as the
+// run ID selects the newest run; query parameters mirror collector.export_table
+// (layout, columns, metadata, mode, resource_ids, region_ids, year_from,
+// year_to, include_flagged), with list values comma-separated.
+func handleExportDownload(w http.ResponseWriter, r *http.Request, path string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "GET only"})
+		return
+	}
+	name := strings.TrimPrefix(path, "/export/")
+	dot := strings.LastIndexByte(name, '.')
+	if dot <= 0 || strings.Contains(name, "/") {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	runID, format := name[:dot], name[dot+1:]
+
+	args := queryArgs(r.URL.Query())
+	args["format"] = format
+	if runID != "latest" {
+		args["run_id"] = runID
+	}
+	sel, err := selectionFromArgs(args)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	opts, err := tableOptionsFromArgs(args)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	mu.RLock()
+	ds, err := selectValuesLocked(sel)
+	mu.RUnlock()
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	content, _, err := renderTable(ds, opts)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", opts.mediaType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", tableFilename(ds, sel, opts)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write([]byte(content))
+	}
+}
+
+// queryArgs converts URL query parameters into tool-style arguments.
+func queryArgs(q url.Values) map[string]any {
+	args := map[string]any{}
+	for _, key := range []string{"layout", "mode", "resource_id", "year_from", "year_to"} {
+		if v := q.Get(key); v != "" {
+			args[key] = v
+		}
+	}
+	for _, key := range []string{"metadata", "include_flagged"} {
+		if v, err := strconv.ParseBool(q.Get(key)); err == nil {
+			args[key] = v
+		}
+	}
+	for _, key := range []string{"columns", "resource_ids", "region_ids"} {
+		if v := q.Get(key); v != "" {
+			list := make([]any, 0)
+			for _, part := range strings.Split(v, ",") {
+				list = append(list, part)
+			}
+			args[key] = list
+		}
+	}
+	return args
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ---------- Turtle ----------
+
+// writeTurtle groups triples by subject (first-appearance order) and predicate,
+// abbreviating IRIs with prefixes where the local part is a plain name.
+func writeTurtle(g *rdfGraph, prefixes map[string]string) string {
+	var b strings.Builder
+	names := sortedKeys(prefixes)
+	for _, p := range names {
+		fmt.Fprintf(&b, "@prefix %s: <%s> .\n", p, escapeIRI(prefixes[p]))
+	}
+
+	type predObjs struct {
+		pred string
+		objs []rdfTerm
+	}
+	bySubject := map[rdfTerm][]*predObjs{}
+	seenTriple := map[rdfTriple]bool{}
+	for _, t := range g.Triples {
+		if seenTriple[t] {
+			continue
+		}
+		seenTriple[t] = true
+		var po *predObjs
+		for _, x := range bySubject[t.S] {
+			if x.pred == t.P.Value {
+				po = x
+				break
+			}
+		}
+		if po == nil {
+			po = &predObjs{pred: t.P.Value}
+			bySubject[t.S] = append(bySubject[t.S], po)
+		}
+		po.objs = append(po.objs, t.O)
+	}
+
+	for _, s := range g.subjects() {
+		preds := bySubject[s]
+		sort.SliceStable(preds, func(i, j int) bool { return preds[i].pred == rdfType && preds[j].pred != rdfType })
+		b.WriteString("\n")
+		b.WriteString(turtleTerm(s, prefixes))
+		for i, po := range preds {
+			if i > 0 {
+				b.WriteString(" ;\n   ")
+			}
+			if po.pred == rdfType {
+				b.WriteString(" a ")
+			} else {
+				b.WriteString(" " + turtleIRI(po.pred, prefixes) + " ")
+			}
+			for k, o := range po.objs {
+				if k > 0 {
+					b.WriteString(", ")
+				}
+				b.WriteString(turtleTerm(o, prefixes))
+			}
+		}
+		b.WriteString(" .\n")
+	}
+	return b.String()
+}
+
+func turtleTerm(t rdfTerm, prefixes map[string]string) string {
+	if t.Kind == termIRI {
+		return turtleIRI(t.Value, prefixes)
+	}
+	if t.Kind == termLiteral && t.Lang == "" && t.Datatype != xsdString {
+		return `"` + escapeLiteral(t.Value) + `"^^` + turtleIRI(t.Datatype, prefixes)
+	}
+	return t.nt()
+}
+
+func turtleIRI(iri string, prefixes map[string]string) string {
+	best, bestLen := "", 0
+	for p, ns := range prefixes {
+		if len(ns) > bestLen && strings.HasPrefix(iri, ns) && isPlainLocalName(iri[len(ns):]) {
+			best, bestLen = p, len(ns)
+		}
+	}
+	if bestLen > 0 {
+		return best + ":" + iri[bestLen:]
+	}
+	return "<" + escapeIRI(iri) + ">"
+}
+
+// isPlainLocalName accepts the subset of PN_LOCAL that needs no escaping.
+func isPlainLocalName(s string) bool {
+	if s == "" {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !isAlnum(c) && c != '_' && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return s[0] != '-' && s[0] != '.' && s[len(s)-1] != '.'
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ---------- Turtle reader ----------
+
+// parseTurtle reads Turtle covering prefixes, prefixed names, 'a', predicate
+// and object lists, typed and language-tagged literals, numeric and boolean
+// shorthands, and anonymous blank nodes. Collections and @base are rejected.
+func parseTurtle(src string) (rdfGraph, error) {
+	p := &turtleParser{rdfLexer: rdfLexer{src: src}, prefixes: map[string]string{}}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return p.g, nil
+		}
+		if err := p.statement(); err != nil {
+			line := strings.Count(p.src[:p.pos], "\n") + 1
+			return p.g, fmt.Errorf("turtle line %d: %w", line, err)
+		}
+	}
+}
+
+type turtleParser struct {
+	rdfLexer
+	prefixes map[string]string
+	g        rdfGraph
+	anon     int
+}
+
+func (p *turtleParser) statement() error {
+	rest := p.src[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, "@prefix"):
+		p.pos += len("@prefix")
+		if err := p.prefixDecl(); err != nil {
+			return err
+		}
+		return p.expect('.')
+	case len(rest) >= 6 && strings.EqualFold(rest[:6], "PREFIX"):
+		p.pos += 6
+		return p.prefixDecl()
+	case strings.HasPrefix(rest, "@base") || (len(rest) >= 4 && strings.EqualFold(rest[:4], "BASE")):
+		return fmt.Errorf("@base is not supported")
+	}
+	subj, err := p.subject()
+	if err != nil {
+		return err
+	}
+	if err := p.predicateObjectList(subj); err != nil {
+		return err
+	}
+	return p.expect('.')
+}
+
+func (p *turtleParser) prefixDecl() error {
+	p.skipSpace()
+	colon := strings.IndexByte(p.src[p.pos:], ':')
+	if colon < 0 {
+		return fmt.Errorf("malformed prefix declaration")
+	}
+	name := strings.TrimSpace(p.src[p.pos : p.pos+colon])
+	p.pos += colon + 1
+	p.skipSpace()
+	if p.peek() != '<' {
+		return fmt.Errorf("expected IRI for prefix %q", name)
+	}
+	iri, err := p.iriRef()
+	if err != nil {
+		return err
+	}
+	p.prefixes[name] = iri
+	return nil
+}
+
+func (p *turtleParser) expect(c byte) error {
+	p.skipSpace()
+	if p.peek() != c {
+		return fmt.Errorf("expected %q at offset %d", c, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *turtleParser) subject() (rdfTerm, error) {
+	p.skipSpace()
+	switch p.peek() {
+	case '<':
+		v, err := p.iriRef()
+		return iriTerm(v), err
+	case '_':
+		return p.blankNode()
+	case '[':
+		return p.anonNode()
+	}
+	v, err := p.prefixedName()
+	return iriTerm(v), err
+}
+
+func (p *turtleParser) predicateObjectList(subj rdfTerm) error {
+	for {
+		p.skipSpace()
+		var pred string
+		if p.peek() == 'a' && p.pos+1 < len(p.src) && !isNameChar(p.src[p.pos+1]) && p.src[p.pos+1] != ':' {
+			p.pos++
+			pred = rdfType
+		} else if p.peek() == '<' {
+			v, err := p.iriRef()
+			if err != nil {
+				return err
+			}
+			pred = v
+		} else {
+			v, err := p.prefixedName()
+			if err != nil {
+				return err
+			}
+			pred = v
+		}
+		for {
+			obj, err := p.object()
+			if err != nil {
+				return err
+			}
+			p.g.Triples = append(p.g.Triples, rdfTriple{S: subj, P: iriTerm(pred), O: obj})
+			p.skipSpace()
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+		p.skipSpace()
+		if p.peek() != ';' {
+			return nil
+		}
+		for p.peek() == ';' {
+			p.pos++
+			p.skipSpace()
+		}
+		if c := p.peek(); c == '.' || c == ']' {
+			return nil
+		}
+	}
+}
+
+func (p *turtleParser) object() (rdfTerm, error) {
+	p.skipSpace()
+	c := p.peek()
+	switch {
+	case c == '<':
+		v, err := p.iriRef()
+		return iriTerm(v), err
+	case c == '_':
+		return p.blankNode()
+	case c == '[':
+		return p.anonNode()
+	case c == '"':
+		return p.literal(func() (string, error) {
+			if p.peek() == '<' {
+				return p.iriRef()
+			}
+			return p.prefixedName()
+		})
+	case c == '(':
+		return rdfTerm{}, fmt.Errorf("collections are not supported")
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return p.number()
+	case strings.HasPrefix(p.src[p.pos:], "true") || strings.HasPrefix(p.src[p.pos:], "false"):
+		word := "true"
+		if c == 'f' {
+			word = "false"
+		}
+		end := p.pos + len(word)
+		if end >= len(p.src) || !isNameChar(p.src[end]) && p.src[end] != ':' {
+			p.pos = end
+			return literalTerm(word, xsdBoolean), nil
+		}
+	}
+	v, err := p.prefixedName()
+	return iriTerm(v), err
+}
+
+func (p *turtleParser) anonNode() (rdfTerm, error) {
+	p.pos++
+	p.anon++
+	node := blankTerm(fmt.Sprintf("anon%d", p.anon))
+	p.skipSpace()
+	if p.peek() == ']' {
+		p.pos++
+		return node, nil
+	}
+	if err := p.predicateObjectList(node); err != nil {
+		return rdfTerm{}, err
+	}
+	return node, p.expect(']')
+}
+
+func (p *turtleParser) number() (rdfTerm, error) {
+	start := p.pos
+	if c := p.peek(); c == '+' || c == '-' {
+		p.pos++
+	}
+	datatype := xsdInteger
+scan:
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c >= '0' && c <= '9':
+		case c == '.' && p.pos+1 < len(p.src) && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9':
+			datatype = xsdDecimal
+		case c == 'e' || c == 'E':
+			datatype = xsdDouble
+			if n := p.pos + 1; n < len(p.src) && (p.src[n] == '+' || p.src[n] == '-') {
+				p.pos++
+			}
+		default:
+			break scan
+		}
+		p.pos++
+	}
+	if p.pos == start {
+		return rdfTerm{}, fmt.Errorf("malformed number at offset %d", start)
+	}
+	return literalTerm(p.src[start:p.pos], datatype), nil
+}
+
+func (p *turtleParser) prefixedName() (string, error) {
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] != ':' && isNameChar(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.peek() != ':' {
+		return "", fmt.Errorf("expected prefixed name at offset %d", start)
+	}
+	prefix := p.src[start:p.pos]
+	p.pos++
+	localStart := p.pos
+	for p.pos < len(p.src) && isNameChar(p.src[p.pos]) {
+		p.pos++
+	}
+	for p.pos > localStart && p.src[p.pos-1] == '.' {
+		p.pos--
+	}
+	ns, ok := p.prefixes[prefix]
+	if !ok {
+		return "", fmt.Errorf("undeclared prefix %q", prefix)
+	}
+	return ns + p.src[localStart:p.pos], nil
+}
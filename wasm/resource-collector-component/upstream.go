@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ---------- upstream circuit breakers ----------
+
+// A breaker opens after breakerThreshold consecutive failures against a source
+// and rejects calls until breakerCooldown has elapsed, so an outage at the
+// World Bank API doesn't cost 150 sequential 30s timeouts per run.
+const (
+	breakerThreshold = 5
+	breakerCooldown  = 5 * time.Minute
+)
+
+const sourceWorldBank = "worldbank"
+
+type circuitBreaker struct {
+	Source    string `json:"source"`
+	State     string `json:"state"`
+	Failures  int    `json:"consecutive_failures"`
+	OpenUntil string `json:"open_until,omitempty"`
+	openUntil time.Time
+	probing   bool
+}
+
+var (
+	breakerMu sync.Mutex
+	breakers  = map[string]*circuitBreaker{
+		sourceWorldBank: {Source: sourceWorldBank, State: "closed"},
+	}
+)
+
+// breakerAllow reports whether a call to source may proceed. An open breaker
+// whose cooldown has elapsed moves to half-open and lets one probe call
+// through; further calls are rejected until breakerRecord sees its outcome.
+func breakerAllow(source string) error {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	b := breakers[source]
+	if b == nil || b.State == "closed" {
+		return nil
+	}
+	if b.State == "open" {
+		if !time.Now().After(b.openUntil) {
+			return fmt.Errorf("circuit open until %s", b.OpenUntil)
+		}
+		b.State = "half-open"
+	}
+	if b.probing {
+		return fmt.Errorf("circuit half-open; probe call in flight")
+	}
+	b.probing = true
+	return nil
+}
+
+// breakerRecord updates the breaker for source with the outcome of a call.
+// Only transport failures and 5xx responses count against the source.
+func breakerRecord(source string, failed bool) {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	b := breakers[source]
+	if b == nil {
+		b = &circuitBreaker{Source: source, State: "closed"}
+		breakers[source] = b
+	}
+	b.probing = false
+	if !failed {
+		b.State, b.Failures, b.OpenUntil, b.openUntil = "closed", 0, "", time.Time{}
+		return
+	}
+	b.Failures++
+	if b.State == "half-open" || b.Failures >= breakerThreshold {
+		b.State = "open"
+		b.openUntil = time.Now().Add(breakerCooldown)
+		b.OpenUntil = b.openUntil.UTC().Format(time.RFC3339)
+	}
+}
+
+func breakerSnapshot() []circuitBreaker {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	out := make([]circuitBreaker, 0, len(breakers))
+	for _, b := range breakers {
+		cp := *b
+		if cp.State == "open" && time.Now().After(cp.openUntil) {
+			cp.State = "half-open"
+		}
+		out = append(out, cp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Source < out[j].Source })
+	return out
+}